@@ -14,21 +14,60 @@ type Config struct {
 	Database struct {
 		Driver string `mapstructure:"driver"`
 		DSN    string `mapstructure:"dsn"`
+		// Резервные копии SQLite; пустой dir отключает их
+		Backup struct {
+			Dir           string `mapstructure:"dir"`
+			IntervalHours int    `mapstructure:"interval_hours"`
+			// Сколько последних копий хранить
+			Keep int `mapstructure:"keep"`
+			// Отправлять свежую копию администратору в Telegram
+			SendToAdmin bool `mapstructure:"send_to_admin"`
+		} `mapstructure:"backup"`
 	} `mapstructure:"database"`
 	Marzban struct {
 		APIURL   string `mapstructure:"api_url"`
 		APIKey   string `mapstructure:"api_key"`
 		Username string `mapstructure:"username"`
 		Password string `mapstructure:"password"`
+		// Протокол -> теги инбаундов для новых пользователей, например
+		// shadowsocks: ["Shadowsocks TCP"]
+		Inbounds map[string][]string `mapstructure:"inbounds"`
 	} `mapstructure:"marzban"`
 	Payments struct {
 		WebhookSecret string `mapstructure:"webhook_secret"`
 		Provider      string `mapstructure:"provider"`
 	} `mapstructure:"payments"`
+	// Чат поддержки, куда пересылаются обращения пользователей;
+	// нулевой chat_id отключает поддержку
+	Support struct {
+		ChatID int64 `mapstructure:"chat_id"`
+		// Тема (топик) в чате-форуме, 0 — без темы
+		ThreadID int `mapstructure:"thread_id"`
+	} `mapstructure:"support"`
 	App struct {
 		TestPeriodDays        int `mapstructure:"test_period_days"`
 		DefaultTrafficLimitGB int `mapstructure:"default_traffic_limit_gb"`
 		CheckIntervalMinutes  int `mapstructure:"check_interval_minutes"`
+		// Путь к access-логу Xray; пустое значение отключает контроль устройств
+		AccessLogPath string `mapstructure:"access_log_path"`
+		// Часовой пояс времени в access-логе (например, UTC или
+		// Europe/Moscow), по умолчанию часовой пояс бота
+		AccessLogTimezone string `mapstructure:"access_log_timezone"`
+		// Через сколько минут без соединений IP перестаёт считаться
+		// активным, по умолчанию 5
+		DeviceIdleMinutes int `mapstructure:"device_idle_minutes"`
+		// Со скольких IP одновременно допускается использовать один конфиг
+		DeviceIPLimit int `mapstructure:"device_ip_limit"`
+		// После скольких нарушений подряд конфиг отключается
+		IPViolationsToDisable int `mapstructure:"ip_violations_to_disable"`
+		// Каталог с шаблонами инструкций по платформам, по умолчанию guides
+		GuidesDir string `mapstructure:"guides_dir"`
+		// Максимальное количество устройств для каждого тарифа
+		DeviceLimits struct {
+			Trial  int `mapstructure:"trial"`
+			Paid   int `mapstructure:"paid"`
+			Friend int `mapstructure:"friend"`
+		} `mapstructure:"device_limits"`
 	} `mapstructure:"app"`
 }
 
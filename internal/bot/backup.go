@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"go-vpn-bot/internal/database"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultBackupInterval = 24 * time.Hour
+	defaultBackupKeep     = 7
+)
+
+// StartBackupJob периодически сохраняет резервную копию базы SQLite
+// и при необходимости отправляет её администратору.
+func (h *BotHandler) StartBackupJob() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if cfg.Database.Backup.Dir == "" {
+		log.Printf("Резервное копирование отключено: не задан database.backup.dir")
+		return
+	}
+	if _, ok := h.DB.(database.Backuper); !ok {
+		log.Printf("Резервное копирование недоступно для текущего хранилища")
+		return
+	}
+
+	interval := time.Duration(cfg.Database.Backup.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = defaultBackupInterval
+	}
+
+	for {
+		time.Sleep(interval)
+		if _, err := h.runBackup(context.Background(), cfg); err != nil {
+			logWithLocation("Ошибка резервного копирования: %v", err)
+			h.SendNotificationToChannel(fmt.Sprintf("⚠️ Не удалось создать резервную копию базы: %v", err))
+		}
+	}
+}
+
+// runBackup создаёт копию и отправляет её администратору, если это
+// включено в конфигурации. Возвращает путь к копии.
+func (h *BotHandler) runBackup(ctx context.Context, cfg *config.Config) (string, error) {
+	backuper, ok := h.DB.(database.Backuper)
+	if !ok {
+		return "", database.ErrBackupUnsupported
+	}
+
+	keep := cfg.Database.Backup.Keep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+
+	path, err := backuper.Backup(ctx, cfg.Database.Backup.Dir, keep)
+	if err != nil {
+		return path, err
+	}
+	log.Printf("Резервная копия базы сохранена: %s", path)
+
+	if cfg.Database.Backup.SendToAdmin && cfg.Bot.AdminID != 0 {
+		doc := tgbotapi.NewDocument(cfg.Bot.AdminID, tgbotapi.FilePath(path))
+		doc.Caption = "🗄 Резервная копия базы " + filepath.Base(path)
+		if _, err := h.Bot.Send(doc); err != nil {
+			return path, fmt.Errorf("копия сохранена, но не отправлена администратору: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// handleBackupCommand создаёт резервную копию по команде администратора.
+func (h *BotHandler) handleBackupCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	if cfg.Database.Backup.Dir == "" {
+		h.sendText(message.Chat.ID, "Резервное копирование отключено: не задан database.backup.dir")
+		return
+	}
+
+	path, err := h.runBackup(ctx, cfg)
+	if errors.Is(err, database.ErrBackupUnsupported) {
+		h.sendText(message.Chat.ID, "Резервное копирование поддерживается только для SQLite")
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка резервного копирования: %v", err)
+		h.sendText(message.Chat.ID, fmt.Sprintf("Не удалось создать резервную копию: %v", err))
+		return
+	}
+
+	h.recordEvent(ctx, database.EventAdminAction, database.ActorAdmin(message.From.ID), 0, map[string]interface{}{
+		"command": "backup",
+		"file":    filepath.Base(path),
+	})
+	if !cfg.Database.Backup.SendToAdmin {
+		h.sendText(message.Chat.ID, "Резервная копия сохранена: "+path)
+	}
+}
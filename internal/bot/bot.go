@@ -1,16 +1,18 @@
 package bot
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // RunBot - запуск бота
-func RunBot(database *database.DB, botToken string) {
+func RunBot(database database.Store, botToken string) {
 	// Создаем объект бота
 	bot, err := tgbotapi.NewBotAPI(botToken)
 	if err != nil {
@@ -29,6 +31,8 @@ func RunBot(database *database.DB, botToken string) {
 	}
 
 	go handler.StartDailySubscriptionCheck()
+	go handler.StartDeviceLimitCheck()
+	go handler.StartBackupJob()
 
 	// Настраиваем получение обновлений
 	// u := tgbotapi.NewUpdate(0)
@@ -56,7 +60,9 @@ func RunBot(database *database.DB, botToken string) {
 		if update.CallbackQuery != nil {
 			callbackTime := time.Unix(int64(update.CallbackQuery.Message.Date), 0)
 			if callbackTime.Before(botStartTime) {
-				hint := tgbotapi.NewCallback(update.CallbackQuery.ID, "Бот был перезагружен, используйте /start")
+				from := update.CallbackQuery.From
+				lang := handler.chatLanguage(context.Background(), from.ID, from)
+				hint := tgbotapi.NewCallback(update.CallbackQuery.ID, i18n.T(lang, "callback.restarted"))
 				_, _ = bot.Request(hint)
 				continue
 			}
@@ -65,6 +71,13 @@ func RunBot(database *database.DB, botToken string) {
 			continue
 		}
 
+		// Блокировку и разблокировку бота учитываем всегда, даже если
+		// событие пришло до запуска
+		if update.MyChatMember != nil {
+			handler.HandleUpdate(update)
+			continue
+		}
+
 		if update.Message != nil {
 			messageTime := time.Unix(int64(update.Message.Date), 0)
 			if messageTime.Before(botStartTime) {
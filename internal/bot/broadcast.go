@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-vpn-bot/internal/database"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// broadcastInterval ограничивает скорость рассылки: Telegram допускает
+// около 30 сообщений в секунду в разные чаты.
+const broadcastInterval = 50 * time.Millisecond
+
+// handleBroadcastCommand начинает рассылку: /broadcast. Бот ждёт сообщение,
+// показывает его администратору и отправляет всем после подтверждения.
+func (h *BotHandler) handleBroadcastCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	if err := h.setState(ctx, message.Chat.ID, stateBroadcast, nil); err != nil {
+		logWithLocation("Ошибка сохранения состояния: %v", err)
+		h.sendText(message.Chat.ID, "Не удалось начать рассылку, попробуйте позже")
+		return
+	}
+	h.sendText(message.Chat.ID, "📣 Отправьте сообщение для рассылки: текст, фото или видео с подписью.\n\n/cancel — отмена")
+}
+
+// handleBroadcastInput запоминает сообщение рассылки и просит подтверждения.
+// Новое сообщение до подтверждения заменяет предыдущее.
+func (h *BotHandler) handleBroadcastInput(ctx context.Context, message *tgbotapi.Message, state database.UserState) error {
+	data := map[string]string{"message_id": strconv.Itoa(message.MessageID)}
+	if err := h.setState(ctx, message.Chat.ID, stateBroadcastConfirm, data); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния: %w", err)
+	}
+
+	buttonSend := tgbotapi.NewInlineKeyboardButtonData("✅ Отправить всем", actionBroadcast)
+	buttonCancel := tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", actionCancel)
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Сообщение выше будет отправлено всем пользователям. Отправить?")
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttonSend, buttonCancel))
+	_, err := h.Bot.Send(msg)
+	return err
+}
+
+// handleBroadcastConfirm запускает подтверждённую рассылку в фоне.
+func (h *BotHandler) handleBroadcastConfirm(ctx context.Context, req *callbackRequest) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+	if req.Query.From == nil || req.Query.From.ID != cfg.Bot.AdminID {
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+
+	state, ok := h.currentState(ctx, req.ChatID)
+	if !ok || state.State != stateBroadcastConfirm {
+		req.Answer("Рассылка устарела, начните заново: /broadcast")
+		return nil
+	}
+	messageID, err := strconv.Atoi(state.Data["message_id"])
+	if err != nil {
+		h.clearState(ctx, req.ChatID)
+		return fmt.Errorf("некорректное сообщение рассылки %q: %w", state.Data["message_id"], err)
+	}
+	h.clearState(ctx, req.ChatID)
+
+	users, err := h.DB.GetAllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("ошибка получения пользователей: %w", err)
+	}
+
+	h.recordEvent(ctx, database.EventAdminAction, database.ActorAdmin(req.Query.From.ID), 0, map[string]interface{}{
+		"command": "broadcast",
+		"users":   len(users),
+	})
+
+	req.Answer("Рассылка запущена")
+	editMsg := tgbotapi.NewEditMessageText(req.ChatID, req.MessageID, fmt.Sprintf("📣 Рассылка запущена, получателей: %d", len(users)))
+	if _, err := h.Bot.Send(editMsg); err != nil {
+		logWithLocation("Ошибка отправки сообщения: %v", err)
+	}
+
+	go h.broadcast(context.Background(), req.ChatID, messageID, users)
+	return nil
+}
+
+// broadcast копирует сообщение администратора пользователям, которые не
+// заблокировали бота и не удалили данные, и присылает итог.
+func (h *BotHandler) broadcast(ctx context.Context, fromChatID int64, messageID int, users []database.User) {
+	var sent, blocked, failed int
+	for _, user := range users {
+		if user.BlockedBot || user.DeletedAt.Valid {
+			continue
+		}
+
+		_, err := h.Bot.Send(tgbotapi.NewCopyMessage(user.ID, fromChatID, messageID))
+		switch {
+		case err == nil:
+			sent++
+		case isBlockedByUser(err):
+			blocked++
+			if err := h.DB.SetBlockedBot(ctx, user.ID, true); err != nil {
+				logWithLocation("Ошибка обновления статуса блокировки пользователя %d: %v", user.ID, err)
+			}
+		default:
+			failed++
+			logWithLocation("Ошибка отправки рассылки пользователю %d: %v", user.ID, err)
+		}
+		time.Sleep(broadcastInterval)
+	}
+
+	h.sendText(fromChatID, fmt.Sprintf("📣 Рассылка завершена\n\nДоставлено: %d\nЗаблокировали бота: %d\nОшибок: %d", sent, blocked, failed))
+}
@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+	"go-vpn-bot/internal/marzban"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultDeviceIPLimit         = 2
+	defaultIPViolationsToDisable = 3
+	defaultDeviceCheckInterval   = 5 * time.Minute
+	defaultDeviceIdle            = 5 * time.Minute
+)
+
+// deviceLimiter хранит состояние проверки: позицию в access-логе,
+// промежутки активности IP и количество нарушений подряд для каждого
+// пользователя Marzban.
+type deviceLimiter struct {
+	logPath string
+	// location — часовой пояс времени в строках лога
+	location   *time.Location
+	offset     int64
+	idle       time.Duration
+	activity   map[string]map[string]marzban.IPActivity
+	violations map[string]int
+}
+
+// StartDeviceLimitCheck периодически проверяет, со скольких IP каждый
+// конфиг используется одновременно, предупреждает нарушителей и
+// отключает конфиг после повторных нарушений.
+func (h *BotHandler) StartDeviceLimitCheck() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if cfg.App.AccessLogPath == "" {
+		log.Printf("Контроль количества устройств отключен: не задан access_log_path")
+		return
+	}
+
+	interval := time.Duration(cfg.App.CheckIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultDeviceCheckInterval
+	}
+
+	location := time.Local
+	if cfg.App.AccessLogTimezone != "" {
+		location, err = time.LoadLocation(cfg.App.AccessLogTimezone)
+		if err != nil {
+			logWithLocation("Контроль количества устройств отключен: неизвестный access_log_timezone %q: %v", cfg.App.AccessLogTimezone, err)
+			return
+		}
+	}
+
+	idle := time.Duration(cfg.App.DeviceIdleMinutes) * time.Minute
+	if idle <= 0 {
+		idle = defaultDeviceIdle
+	}
+
+	limiter := &deviceLimiter{
+		logPath:    cfg.App.AccessLogPath,
+		location:   location,
+		idle:       idle,
+		activity:   make(map[string]map[string]marzban.IPActivity),
+		violations: make(map[string]int),
+	}
+
+	// Пропускаем накопленный лог, чтобы не наказывать за старые подключения
+	if info, err := os.Stat(limiter.logPath); err == nil {
+		limiter.offset = info.Size()
+	}
+
+	for {
+		time.Sleep(interval)
+		h.checkDeviceLimits(context.Background(), limiter)
+	}
+}
+
+func (h *BotHandler) checkDeviceLimits(ctx context.Context, limiter *deviceLimiter) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	ipLimit := cfg.App.DeviceIPLimit
+	if ipLimit <= 0 {
+		ipLimit = defaultDeviceIPLimit
+	}
+	maxViolations := cfg.App.IPViolationsToDisable
+	if maxViolations <= 0 {
+		maxViolations = defaultIPViolationsToDisable
+	}
+
+	if err := limiter.readAccessLog(); err != nil {
+		logWithLocation("Ошибка чтения access-лога: %v", err)
+		return
+	}
+	limiter.forgetIdle(time.Now())
+
+	for username := range limiter.violations {
+		if _, ok := limiter.activity[username]; !ok {
+			delete(limiter.violations, username)
+		}
+	}
+
+	for username, activity := range limiter.activity {
+		ips := marzban.ConcurrentIPs(activity)
+		if ips <= ipLimit {
+			delete(limiter.violations, username)
+			continue
+		}
+
+		userID, deviceNumber, ok := parseDeviceUsername(username)
+		if !ok {
+			continue
+		}
+
+		limiter.violations[username]++
+		count := limiter.violations[username]
+		log.Printf("Конфиг %s используется одновременно с %d IP (лимит %d), нарушение %d из %d", username, ips, ipLimit, count, maxViolations)
+
+		if count < maxViolations {
+			h.sendText(userID, i18n.T(h.chatLanguage(ctx, userID, nil), "notify.ip_warning", deviceNumber, ips))
+			continue
+		}
+
+		if err := setUserStatusMarzban(username, "disabled"); err != nil {
+			logWithLocation("Ошибка отключения конфига %s: %v", username, err)
+			continue
+		}
+		delete(limiter.violations, username)
+		delete(limiter.activity, username)
+
+		device, err := h.DB.GetDeviceByMarzbanUsername(ctx, username)
+		if err != nil {
+			logWithLocation("Ошибка получения устройства %s: %v", username, err)
+		} else if err := h.DB.UpdateDeviceStatus(ctx, device.ID, database.DeviceStatusDisabled); err != nil {
+			logWithLocation("Ошибка обновления статуса устройства %s: %v", username, err)
+		}
+		h.recordEvent(ctx, database.EventDeviceDisabled, database.ActorSystem, userID, map[string]interface{}{
+			"device": username,
+			"reason": "ip_limit",
+		})
+
+		h.sendText(userID, i18n.T(h.chatLanguage(ctx, userID, nil), "notify.ip_disabled", deviceNumber))
+		h.SendNotificationToChannel(fmt.Sprintf("Конфиг %s отключен: %d IP одновременно при лимите %d", username, ips, ipLimit))
+	}
+}
+
+// readAccessLog дочитывает access-лог с момента прошлой проверки и
+// расширяет промежутки активности IP. Позиция сдвигается только на полные
+// строки. Если файл был ротирован или обрезан, чтение начинается с начала.
+func (l *deviceLimiter) readAccessLog() error {
+	file, err := os.Open(l.logPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < l.offset {
+		l.offset = 0
+	}
+
+	if _, err := file.Seek(l.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	consumed, err := marzban.ReadAccessLog(io.LimitReader(file, info.Size()-l.offset), l.location, func(entry marzban.AccessLogEntry) {
+		ips, ok := l.activity[entry.Username]
+		if !ok {
+			ips = make(map[string]marzban.IPActivity)
+			l.activity[entry.Username] = ips
+		}
+		activity := ips[entry.IP]
+		activity.Add(entry.Time)
+		ips[entry.IP] = activity
+	})
+	l.offset += consumed
+	return err
+}
+
+// forgetIdle забывает IP, с которых не было соединений дольше device_idle_minutes:
+// устройство ушло из этой сети, и новый IP не должен считаться
+// одновременным с ним.
+func (l *deviceLimiter) forgetIdle(now time.Time) {
+	cutoff := now.Add(-l.idle)
+	for username, ips := range l.activity {
+		for ip, activity := range ips {
+			if activity.Last.Before(cutoff) {
+				delete(ips, ip)
+			}
+		}
+		if len(ips) == 0 {
+			delete(l.activity, username)
+		}
+	}
+}
+
+// parseDeviceUsername разбирает имя пользователя Marzban вида "<chatID>_device<N>".
+func parseDeviceUsername(username string) (int64, int, bool) {
+	idPart, devicePart, ok := strings.Cut(username, "_device")
+	if !ok {
+		return 0, 0, false
+	}
+	userID, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	deviceNumber, err := strconv.Atoi(devicePart)
+	if err != nil {
+		return 0, 0, false
+	}
+	return userID, deviceNumber, true
+}
+
+// setUserStatusMarzban включает ("active") или отключает ("disabled")
+// пользователя Marzban.
+func setUserStatusMarzban(username, status string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	err = marzban.SetUserStatus(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username, status)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return err
+		}
+		err = marzban.SetUserStatus(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username, status)
+	}
+	return err
+}
+
+func (h *BotHandler) sendText(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if _, err := h.Bot.Send(msg); err != nil {
+		logWithLocation("Ошибка отправки сообщения пользователю %d: %v", chatID, err)
+	}
+}
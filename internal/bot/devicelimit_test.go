@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-vpn-bot/internal/marzban"
+)
+
+// TestDeviceLimiterTimezone проверяет, что время строк лога читается в
+// часовом поясе лога, а не бота, и IP забываются по device_idle_minutes.
+func TestDeviceLimiterTimezone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	lines := "2024/05/10 10:15:32 from 1.2.3.4:54321 accepted tcp:example.com:443 email: 1.1_device1\n" +
+		"2024/05/10 10:18:00 from 5.6.7.8:1000 accepted tcp:example.com:443 email: 1.1_device1\n"
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	limiter := &deviceLimiter{
+		logPath:    path,
+		location:   moscow,
+		idle:       10 * time.Minute,
+		activity:   make(map[string]map[string]marzban.IPActivity),
+		violations: make(map[string]int),
+	}
+	if err := limiter.readAccessLog(); err != nil {
+		t.Fatalf("readAccessLog: %v", err)
+	}
+
+	want := time.Date(2024, 5, 10, 7, 15, 32, 0, time.UTC)
+	if got := limiter.activity["1_device1"]["1.2.3.4"].Last; !got.Equal(want) {
+		t.Errorf("last activity = %v, want %v", got, want)
+	}
+
+	// Через 12 минут после первой строки по UTC первый IP простаивает
+	// дольше 10 минут, второй — нет
+	limiter.forgetIdle(want.Add(12 * time.Minute))
+	ips := limiter.activity["1_device1"]
+	if _, ok := ips["1.2.3.4"]; ok {
+		t.Error("idle IP 1.2.3.4 was not forgotten")
+	}
+	if _, ok := ips["5.6.7.8"]; !ok {
+		t.Error("active IP 5.6.7.8 was forgotten")
+	}
+}
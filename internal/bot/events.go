@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-vpn-bot/internal/database"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// eventsCommandLimit — сколько последних событий показывает /events.
+const eventsCommandLimit = 20
+
+// recordEvent пишет событие в журнал. Ошибка журнала не должна прерывать
+// действие пользователя, поэтому она только логируется.
+func (h *BotHandler) recordEvent(ctx context.Context, eventType, actor string, userID int64, details map[string]interface{}) {
+	event := &database.Event{
+		Type:    eventType,
+		Actor:   actor,
+		UserID:  userID,
+		Details: details,
+	}
+	if err := h.DB.RecordEvent(ctx, event); err != nil {
+		logWithLocation("Ошибка записи события %s для пользователя %d: %v", eventType, userID, err)
+	}
+}
+
+// actorFor возвращает инициатора события для отправителя сообщения,
+// отличая администратора от обычного пользователя.
+func actorFor(from *tgbotapi.User) string {
+	if from == nil {
+		return database.ActorSystem
+	}
+	if cfg, err := config.LoadConfig(); err == nil && from.ID == cfg.Bot.AdminID {
+		return database.ActorAdmin(from.ID)
+	}
+	return database.ActorUser(from.ID)
+}
+
+// handleEventsCommand показывает администратору журнал событий:
+// /events — последние события, /events @username или /events <id> —
+// события пользователя.
+func (h *BotHandler) handleEventsCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	filter := database.EventFilter{Limit: eventsCommandLimit}
+	if query := strings.TrimSpace(message.CommandArguments()); query != "" {
+		userID, err := strconv.ParseInt(query, 10, 64)
+		if err != nil {
+			user, lookupErr := h.DB.GetUserByUsername(ctx, query)
+			if errors.Is(lookupErr, database.ErrNotFound) {
+				h.sendText(message.Chat.ID, fmt.Sprintf("Пользователь %s не найден", query))
+				return
+			}
+			if lookupErr != nil {
+				logWithLocation("Ошибка поиска пользователя %s: %v", query, lookupErr)
+				return
+			}
+			userID = user.ID
+		}
+		filter.UserID = userID
+	}
+
+	events, err := h.DB.ListEvents(ctx, filter)
+	if err != nil {
+		logWithLocation("Ошибка получения журнала событий: %v", err)
+		h.sendText(message.Chat.ID, "Не удалось получить журнал событий, попробуйте позже")
+		return
+	}
+
+	h.sendText(message.Chat.ID, formatEvents(events))
+}
+
+func formatEvents(events []database.Event) string {
+	if len(events) == 0 {
+		return "Событий нет"
+	}
+
+	var b strings.Builder
+	b.WriteString("📜 Журнал событий\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "\n%s %s", e.CreatedAt.Format("02.01.2006 15:04"), e.Type)
+		if e.UserID != 0 {
+			fmt.Fprintf(&b, " пользователь %d", e.UserID)
+		}
+		fmt.Fprintf(&b, " (%s)", e.Actor)
+		if len(e.Details) > 0 {
+			if details, err := json.Marshal(e.Details); err == nil {
+				fmt.Fprintf(&b, " %s", details)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"go-vpn-bot/internal/i18n"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultGuidesDir — каталог инструкций, если app.guides_dir не задан.
+const defaultGuidesDir = "guides"
+
+// maxCaptionLength — ограничение Telegram на длину подписи к фото.
+const maxCaptionLength = 1024
+
+// guidePlatform — платформа, для которой есть инструкция. Шаги лежат в
+// каталоге <guides_dir>/<key>: 01.txt, 02.txt, ... — шаблоны text/template,
+// рядом может лежать скриншот шага с тем же номером (01.jpg или 01.png).
+// Перевод инструкции кладётся в <guides_dir>/<язык>/<key>.
+// Файлы читаются при каждом показе, поэтому правки не требуют перезапуска.
+type guidePlatform struct {
+	key   string
+	title string
+}
+
+var guidePlatforms = []guidePlatform{
+	{"ios", "📱 iOS"},
+	{"android", "📱 Android"},
+	{"mac", "🖥 MacOS"},
+	{"windows", "🖥 Windows"},
+}
+
+func findGuidePlatform(key string) (guidePlatform, bool) {
+	for _, p := range guidePlatforms {
+		if p.key == key {
+			return p, true
+		}
+	}
+	return guidePlatform{}, false
+}
+
+// guideData — данные, доступные в шаблонах шагов.
+type guideData struct {
+	Location        string
+	Link            string
+	SubscriptionURL string
+}
+
+type guideStep struct {
+	// text — готовый текст сообщения шага с заголовком "guides.step"
+	text  string
+	photo string // путь к скриншоту, пустой если его нет
+}
+
+var guidePhotoExtensions = []string{".jpg", ".jpeg", ".png"}
+
+// guideDir возвращает каталог инструкции на языке lang, а если перевода
+// нет — основной каталог платформы.
+func guideDir(dir, lang, platform string) string {
+	translated := filepath.Join(dir, lang, platform)
+	if info, err := os.Stat(translated); err == nil && info.IsDir() {
+		return translated
+	}
+	return filepath.Join(dir, platform)
+}
+
+// loadGuide читает и заполняет шаги инструкции из каталога платформы и
+// оформляет их сообщениями на языке lang.
+func loadGuide(dir, lang string, platform guidePlatform, data guideData) ([]guideStep, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("нет шагов инструкции в %s", dir)
+	}
+	sort.Strings(files)
+
+	steps := make([]guideStep, 0, len(files))
+	for i, file := range files {
+		tmpl, err := template.ParseFiles(file)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона %s: %w", file, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("ошибка заполнения шаблона %s: %w", file, err)
+		}
+
+		text := strings.TrimSpace(buf.String())
+		step := guideStep{text: i18n.T(lang, "guides.step", platform.title, i+1, len(files), text)}
+		base := strings.TrimSuffix(file, ".txt")
+		for _, ext := range guidePhotoExtensions {
+			if _, err := os.Stat(base + ext); err == nil {
+				step.photo = base + ext
+				break
+			}
+		}
+		// Проверяется подпись целиком, вместе с заголовком шага
+		if step.photo != "" && utf8.RuneCountInString(step.text) > maxCaptionLength {
+			return nil, fmt.Errorf("подпись шага %s длиннее %d символов и не помещается под фото", file, maxCaptionLength)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// guidePlatformRows возвращает кнопки выбора платформы по две в ряд.
+func guidePlatformRows() [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(guidePlatforms); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, p := range guidePlatforms[i:min(i+2, len(guidePlatforms))] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(p.title, callbackData(actionGuide, p.key)))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// handleGuideMenu показывает выбор платформы. Шаг инструкции со скриншотом
+// нельзя отредактировать в текст, поэтому такое сообщение заменяется новым.
+func (h *BotHandler) handleGuideMenu(ctx context.Context, req *callbackRequest) error {
+	text, keyboard := guideMenuScreen(req.Lang)
+
+	if req.Query.Message.Photo == nil {
+		return h.editScreen(req, text, keyboard)
+	}
+
+	h.deleteCallbackMessage(req)
+	return h.sendScreen(req.ChatID, text, keyboard)
+}
+
+// handleGuide открывает инструкцию: "guide:<платформа>". Первый шаг
+// отправляется новым сообщением, чтобы не затирать экран с конфигом.
+func (h *BotHandler) handleGuide(ctx context.Context, req *callbackRequest) error {
+	platform, ok := findGuidePlatform(req.String(0))
+	if !ok {
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+	return h.sendGuideStep(ctx, req, platform, 1, false)
+}
+
+// handleGuideStep листает инструкцию: "guide_step:<платформа>:<шаг>".
+// Предыдущий шаг удаляется, так как текстовое сообщение нельзя превратить
+// в фото и наоборот.
+func (h *BotHandler) handleGuideStep(ctx context.Context, req *callbackRequest) error {
+	platform, ok := findGuidePlatform(req.String(0))
+	if !ok {
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+	return h.sendGuideStep(ctx, req, platform, req.Int(1), true)
+}
+
+func (h *BotHandler) sendGuideStep(ctx context.Context, req *callbackRequest, platform guidePlatform, step int, replace bool) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+	dir := cfg.App.GuidesDir
+	if dir == "" {
+		dir = defaultGuidesDir
+	}
+
+	var data guideData
+	devices, err := h.DB.GetUserDevices(ctx, req.ChatID)
+	if err != nil {
+		logWithLocation("Ошибка получения устройств пользователя %d: %v", req.ChatID, err)
+	} else if len(devices) > 0 {
+		data = guideData{Location: devices[0].Location, Link: devices[0].Link, SubscriptionURL: devices[0].SubscriptionURL}
+	}
+
+	steps, err := loadGuide(guideDir(dir, req.Lang, platform.key), req.Lang, platform, data)
+	if err != nil {
+		req.Answer(req.T("guides.unavailable"))
+		return fmt.Errorf("ошибка загрузки инструкции %s: %w", platform.key, err)
+	}
+	if step > len(steps) {
+		step = len(steps)
+	}
+	current := steps[step-1]
+
+	back := actionGuides
+	if step > 1 {
+		back = callbackData(actionGuideStep, platform.key, step-1)
+	}
+	nav := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(req.T("button.back"), back)}
+	if step < len(steps) {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(req.T("guides.next"), callbackData(actionGuideStep, platform.key, step+1)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		nav,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(req.T("guides.other"), actionGuides)),
+	)
+
+	var msg tgbotapi.Chattable
+	if current.photo != "" {
+		photo := tgbotapi.NewPhoto(req.ChatID, tgbotapi.FilePath(current.photo))
+		photo.Caption = current.text
+		photo.ReplyMarkup = keyboard
+		msg = photo
+	} else {
+		textMsg := tgbotapi.NewMessage(req.ChatID, current.text)
+		textMsg.ReplyMarkup = keyboard
+		textMsg.DisableWebPagePreview = true
+		msg = textMsg
+	}
+
+	if replace {
+		h.deleteCallbackMessage(req)
+	}
+	_, err = h.Bot.Send(msg)
+	return err
+}
+
+func (h *BotHandler) deleteCallbackMessage(req *callbackRequest) {
+	del := tgbotapi.NewDeleteMessage(req.ChatID, req.MessageID)
+	if _, err := h.Bot.Request(del); err != nil {
+		logWithLocation("Ошибка удаления сообщения %d: %v", req.MessageID, err)
+	}
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-vpn-bot/internal/i18n"
+)
+
+// writeGuide создаёт файлы инструкции: имя файла — содержимое.
+func writeGuide(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadGuide(t *testing.T) {
+	platform := guidePlatforms[0]
+	data := guideData{Location: testLocation, Link: testLink, SubscriptionURL: testSubURL}
+
+	tests := []struct {
+		name   string
+		files  map[string]string
+		texts  []string // тексты шагов без заголовка
+		photos []string // имена скриншотов по шагам
+		err    bool
+	}{
+		{
+			name:   "order by file name",
+			files:  map[string]string{"10.txt": "third", "02.txt": "second", "01.txt": "first"},
+			texts:  []string{"first", "second", "third"},
+			photos: []string{"", "", ""},
+		},
+		{
+			name:   "template data",
+			files:  map[string]string{"01.txt": "{{.Location}}\n{{.Link}}\n{{.SubscriptionURL}}\n"},
+			texts:  []string{testLocation + "\n" + testLink + "\n" + testSubURL},
+			photos: []string{""},
+		},
+		{
+			name:   "photo next to step",
+			files:  map[string]string{"01.txt": "one", "01.png": "png", "02.txt": "two", "02.jpeg": "jpeg", "03.txt": "three"},
+			texts:  []string{"one", "two", "three"},
+			photos: []string{"01.png", "02.jpeg", ""},
+		},
+		{
+			name:   "long text without photo",
+			files:  map[string]string{"01.txt": strings.Repeat("x", maxCaptionLength)},
+			texts:  []string{strings.Repeat("x", maxCaptionLength)},
+			photos: []string{""},
+		},
+		{
+			// Текст помещается в подпись, но вместе с заголовком шага — нет
+			name:  "caption with title too long",
+			files: map[string]string{"01.txt": strings.Repeat("x", maxCaptionLength-5), "01.jpg": "jpg"},
+			err:   true,
+		},
+		{
+			name:  "broken template",
+			files: map[string]string{"01.txt": "{{.Missing"},
+			err:   true,
+		},
+		{
+			name:  "no steps",
+			files: map[string]string{"readme.md": "nothing"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeGuide(t, dir, tt.files)
+
+			steps, err := loadGuide(dir, i18n.Default, platform, data)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("loadGuide succeeded, steps = %+v", steps)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadGuide: %v", err)
+			}
+			if len(steps) != len(tt.texts) {
+				t.Fatalf("got %d steps, want %d", len(steps), len(tt.texts))
+			}
+			for i, step := range steps {
+				want := i18n.T(i18n.Default, "guides.step", platform.title, i+1, len(tt.texts), tt.texts[i])
+				if step.text != want {
+					t.Errorf("step %d text = %q, want %q", i+1, step.text, want)
+				}
+				wantPhoto := ""
+				if tt.photos[i] != "" {
+					wantPhoto = filepath.Join(dir, tt.photos[i])
+				}
+				if step.photo != wantPhoto {
+					t.Errorf("step %d photo = %q, want %q", i+1, step.photo, wantPhoto)
+				}
+			}
+		})
+	}
+}
+
+func TestGuideDir(t *testing.T) {
+	dir := t.TempDir()
+	writeGuide(t, filepath.Join(dir, "ios"), map[string]string{"01.txt": "ru"})
+	writeGuide(t, filepath.Join(dir, "en", "ios"), map[string]string{"01.txt": "en"})
+	writeGuide(t, filepath.Join(dir, "android"), map[string]string{"01.txt": "ru"})
+
+	tests := []struct {
+		lang, platform string
+		want           string
+	}{
+		{"en", "ios", filepath.Join(dir, "en", "ios")},
+		{"ru", "ios", filepath.Join(dir, "ios")},
+		// Перевода нет — основной каталог платформы
+		{"en", "android", filepath.Join(dir, "android")},
+		{"de", "ios", filepath.Join(dir, "ios")},
+	}
+	for _, tt := range tests {
+		if got := guideDir(dir, tt.lang, tt.platform); got != tt.want {
+			t.Errorf("guideDir(%q, %q) = %q, want %q", tt.lang, tt.platform, got, tt.want)
+		}
+	}
+}
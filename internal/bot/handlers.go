@@ -1,14 +1,18 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
 	"go-vpn-bot/internal/marzban"
 
 	config "go-vpn-bot/configs"
@@ -16,9 +20,16 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	// defaultDeviceLimit используется, если лимит для тарифа не задан в конфигурации
+	defaultDeviceLimit = 3
+	// defaultLocation — сервер, на котором создаются новые устройства
+	defaultLocation = "🇵🇱 Польша"
+)
+
 type BotHandler struct {
 	Bot *tgbotapi.BotAPI
-	DB  *database.DB
+	DB  database.Store
 }
 
 func logWithLocation(format string, args ...interface{}) {
@@ -30,7 +41,7 @@ func logWithLocation(format string, args ...interface{}) {
 	}
 
 	funcName := runtime.FuncForPC(pc).Name()
-	location := log.Prefix() + file + ":" + funcName + ":" + string(line)
+	location := log.Prefix() + file + ":" + funcName + ":" + strconv.Itoa(line)
 	log.Printf(location+" "+format, args...)
 }
 
@@ -55,98 +66,112 @@ func (h *BotHandler) StartDailySubscriptionCheck() {
 
 		time.Sleep(duration)
 
-		h.CheckSubscriptionsAndNotify()
+		h.CheckSubscriptionsAndNotify(context.Background())
 	}
 }
 
-func (h *BotHandler) notifyUser(user database.User, message string) {
+func (h *BotHandler) notifyUser(ctx context.Context, user database.User, message string) {
+	if user.BlockedBot {
+		return
+	}
+
 	msg := tgbotapi.NewMessage(user.ID, message)
 	_, err := h.Bot.Send(msg)
+	if isBlockedByUser(err) {
+		if err := h.DB.SetBlockedBot(ctx, user.ID, true); err != nil {
+			logWithLocation("Ошибка обновления статуса блокировки пользователя %d: %v", user.ID, err)
+		}
+		return
+	}
 	if err != nil {
 		logWithLocation("Ошибка отправки уведомления пользователю %d: %v", user.ID, err)
 	}
 }
 
-func (h *BotHandler) CheckSubscriptionsAndNotify() {
-	users, err := h.DB.GetAllUsers()
-
+func (h *BotHandler) CheckSubscriptionsAndNotify(ctx context.Context) {
+	checkedCount, err := h.DB.CountActiveUsers(ctx)
 	if err != nil {
-		logWithLocation("Ошибка получения пользователей %v", err)
+		logWithLocation("Ошибка подсчета пользователей %v", err)
 		return
 	}
 
-	var checkedCount, deletedCount int
+	var deletedCount int
 	now := time.Now()
 
-	for _, user := range users {
-		subscriptionEnd := user.SubscriptionEndDate.Time
-		daysLeft := int(subscriptionEnd.Sub(now).Hours()/24) + 1
+	// Уведомление за 3 дня
+	err = h.DB.UsersExpiringBetween(ctx, now.Add(48*time.Hour), now.Add(72*time.Hour), func(user database.User) error {
+		lang := userLanguage(user)
+		h.notifyUser(ctx, user, i18n.T(lang, "notify.expiring", i18n.N(lang, "days", 3)))
+		return nil
+	})
+	if err != nil {
+		logWithLocation("Ошибка получения пользователей с истекающей подпиской %v", err)
+	}
 
-		// Уведомление за 3 дня
-		if !user.IsFriend && user.IsActive && daysLeft == 3 {
-			h.notifyUser(user, "Ваша подписка истекает через 3 дня. Пожалуйста, продлите её, чтобы продолжить пользоваться услугами.")
-		}
+	// Отключение выполняется после чтения выборки: пока открыт курсор,
+	// SQLite может не дать записать изменения
+	var expired []database.User
+	err = h.DB.ExpiredActiveUsers(ctx, now, func(user database.User) error {
+		expired = append(expired, user)
+		return nil
+	})
+	if err != nil {
+		logWithLocation("Ошибка получения пользователей с истекшей подпиской %v", err)
+	}
 
-		if !user.IsFriend && user.IsActive && subscriptionEnd.Before(now) {
-			configs := []string{user.Config1, user.Config2, user.Config3}
-
-			for i, configUser := range configs {
-				if configUser != "" {
-					cfg, err := config.LoadConfig()
-					if err != nil {
-						logWithLocation("Ошибка загрузки конфигурации: %v", err)
-						return
-					}
-
-					err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, fmt.Sprintf("%d_device%d", user.ID, i+1))
-					if err != nil {
-						logWithLocation("Получаем новый токен")
-						// Получаем новый токен
-						newAPIKey, err := marzban.GetAPIKey(cfg.Marzban.APIURL, cfg.Marzban.Username, cfg.Marzban.Password)
-						if err != nil {
-							logWithLocation("Не удалось получить новый токен: %v", err)
-							return
-						}
-
-						err = marzban.UpdateAPIKey("configs/config.yaml", newAPIKey)
-						if err != nil {
-							logWithLocation("Не удалось обновить конфиг: %v", err)
-							return
-						}
-
-						// Повторяем запрос CreateUser
-						cfg.Marzban.APIKey = newAPIKey // Обновляем APIKey в памяти
-						err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, fmt.Sprintf("%d_device%d", user.ID, i+1))
-						if err != nil {
-							logWithLocation("Ошибка удаления пользователя даже после обновления токена: %v", err)
-							return
-						}
-					}
-					h.DB.UpdateUserConfig(user.ID, i+1, "")
-				}
-				err = h.DB.UpdateTrialStatus(user.ID, false)
-				if err != nil {
-					logWithLocation("Ошибка обновления тестового статуса у пользователя %d: %v", user.ID, err)
-					return
-				}
-
-				err = h.DB.UpdateActiveStatus(user.ID, false)
-				if err != nil {
-					logWithLocation("Ошибка обновления активного статуса у пользователя %d: %v", user.ID, err)
-					return
-				}
-			}
-			deletedCount++
-			h.notifyUser(user, "Доступ к сервису приостановлен. Оплатите подписку, чтобы продолжить пользоваться услугами.")
-		}
-		if !user.IsFriend && user.IsActive {
-			checkedCount++
+	for _, user := range expired {
+		if err := h.deactivateUser(ctx, user.ID); err != nil {
+			// Пользователь останется активным и будет обработан при следующей проверке
+			logWithLocation("Ошибка отключения пользователя %d: %v", user.ID, err)
+			continue
 		}
+		deletedCount++
+		h.notifyUser(ctx, user, i18n.T(userLanguage(user), "notify.deactivated"))
 	}
+	if _, err := h.DB.DeleteExpiredStates(ctx, now); err != nil {
+		logWithLocation("Ошибка удаления истёкших состояний диалогов: %v", err)
+	}
+
 	// Отправляем информацию в Telegram-канал
 	h.SendCheckResults(checkedCount, deletedCount)
 }
 
+// deactivateUser удаляет конфиги пользователя из Marzban и затем одной
+// транзакцией удаляет его устройства и снимает флаги активности. Если
+// удалить хотя бы один конфиг не удалось, база не изменяется.
+func (h *BotHandler) deactivateUser(ctx context.Context, userID int64) error {
+	devices, err := h.DB.GetUserDevices(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройств: %w", err)
+	}
+
+	for _, device := range devices {
+		if err := deleteUserFromMarzban(device.MarzbanUsername); err != nil {
+			return err
+		}
+	}
+
+	return h.DB.DeactivateUser(ctx, userID)
+}
+
+// handleCheckCommand запускает проверку подписок вне расписания. Команда
+// доступна только администратору.
+func (h *BotHandler) handleCheckCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	h.recordEvent(ctx, database.EventAdminAction, actorFor(message.From), 0, map[string]interface{}{"command": "check"})
+	h.CheckSubscriptionsAndNotify(ctx)
+}
+
 func (h *BotHandler) SendCheckResults(checkedCount, deletedCount int) {
 	// ID вашего канала
 	channelID := "-1002480497483" // Замените на ваш канал
@@ -184,43 +209,89 @@ func (h *BotHandler) SendNotificationToChannel(message string) {
 	}
 }
 
-func (h *BotHandler) HandleMessage(message *tgbotapi.Message) {
+func (h *BotHandler) HandleMessage(ctx context.Context, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			logWithLocation("Ошибка загрузки конфигурации: %v", err)
+			return
+		}
+		// В группах бот отвечает только в чате поддержки
+		if cfg.Support.ChatID != 0 && message.Chat.ID == cfg.Support.ChatID {
+			h.handleSupportChatMessage(ctx, message)
+		}
+		return
+	}
+
 	switch {
 	case strings.HasPrefix(message.Text, "/start"):
-		h.handleStart(message)
+		h.handleStart(ctx, message)
 	case message.Text == "/check":
-		h.CheckSubscriptionsAndNotify()
+		h.handleCheckCommand(ctx, message)
+	case message.Text == "/inbounds":
+		h.handleInboundsCommand(ctx, message)
+	case message.Command() == "user":
+		h.handleUserCommand(ctx, message)
+	case message.Command() == "reset_traffic":
+		h.handleResetTrafficCommand(ctx, message)
+	case message.Command() == "events":
+		h.handleEventsCommand(ctx, message)
+	case message.Command() == "backup":
+		h.handleBackupCommand(ctx, message)
+	case message.Command() == "mydata":
+		h.handleMyDataCommand(ctx, message)
+	case message.Command() == "deleteme":
+		h.handleDeleteMeCommand(ctx, message)
+	case message.Command() == "broadcast":
+		h.handleBroadcastCommand(ctx, message)
+	case message.Command() == "cancel":
+		h.handleCancelCommand(ctx, message)
 	default:
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Неизвестная команда. Введите /start")
-		if _, err := h.Bot.Send(msg); err != nil {
-			log.Printf("Ошибка отправки сообщения: %v", err)
+		// Если бот ждёт ввода, сообщение получает обработчик состояния
+		if !message.IsCommand() && h.handleStateMessage(ctx, message) {
+			return
 		}
+		h.replyUnknownCommand(ctx, message)
 	}
 }
 
-func (h *BotHandler) handleStart(message *tgbotapi.Message) {
+func (h *BotHandler) handleStart(ctx context.Context, message *tgbotapi.Message) {
 	chatID := message.Chat.ID
-	user := h.DB.GetUserByID(chatID)
-	if user == nil {
+	h.clearState(ctx, chatID)
+	user, err := h.DB.GetUserByID(ctx, chatID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		log.Printf("Ошибка получения пользователя %d: %v", chatID, err)
+		return
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		// Язык нового пользователя выбирается по настройкам Telegram
+		lang := i18n.Default
+		if message.From != nil {
+			lang = i18n.Match(message.From.LanguageCode)
+		}
+
 		// Если пользователь не найден, создаем нового с 7 днями пробного периода
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			log.Printf("Ошибка загрузки конфигурации: %v", err)
-			msg := tgbotapi.NewMessage(chatID, "Ошибка загрузки конфигурации.")
-			if _, err := h.Bot.Send(msg); err != nil {
-				log.Printf("Ошибка отправки сообщения: %v", err)
-			}
+			h.sendText(chatID, i18n.T(lang, "start.failed"))
 			return
 		}
 
-		err = h.DB.CreateUser(chatID, cfg.App.TestPeriodDays)
+		err = h.DB.CreateUser(ctx, chatID, cfg.App.TestPeriodDays)
 		if err != nil {
-			msg := tgbotapi.NewMessage(chatID, "Произошла ошибка при создании пользователя.")
-			if _, err := h.Bot.Send(msg); err != nil {
-				log.Printf("Ошибка отправки сообщения: %v", err)
-			}
+			h.sendText(chatID, i18n.T(lang, "start.failed"))
 			return
 		}
+		if message.From != nil {
+			h.touchUser(ctx, message.From)
+		}
+		if err := h.DB.SetUserLanguage(ctx, chatID, lang); err != nil {
+			logWithLocation("Ошибка сохранения языка пользователя %d: %v", chatID, err)
+		}
+		h.recordEvent(ctx, database.EventTrialStarted, database.ActorUser(chatID), chatID, map[string]interface{}{
+			"days": cfg.App.TestPeriodDays,
+		})
 
 		args := strings.Fields(message.Text)
 		var referrerID int64 = 0
@@ -233,7 +304,7 @@ func (h *BotHandler) handleStart(message *tgbotapi.Message) {
 
 		if referrerID != 0 && referrerID != chatID {
 			// Обновляем реферальные данные
-			err = h.DB.UpdateReffererID(referrerID, chatID)
+			err = h.DB.UpdateReffererID(ctx, referrerID, chatID)
 			if err != nil {
 				log.Printf("Ошибка обновления реферала: %v", err)
 			}
@@ -246,664 +317,381 @@ func (h *BotHandler) handleStart(message *tgbotapi.Message) {
 			h.SendNotificationToChannel(notification)
 		}
 
-		// Сообщение для нового пользователя
-		welcomeText := "Добро пожаловать в 👁NoSeeNet👁\n\n" +
-			"🔒 Безопасное соединение\n" +
-			"🌍 Доступ ко всем сайтам\n" +
-			"📈 Высокая скорость"
-
-		// Создаем inline-кнопку
-		button := tgbotapi.NewInlineKeyboardButtonData("🚀 Поехали!", "get_started")
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(button),
-		)
-
-		// Отправляем сообщение с кнопкой
-		msg := tgbotapi.NewMessage(chatID, welcomeText)
-		msg.ReplyMarkup = keyboard
-		if _, err := h.Bot.Send(msg); err != nil {
+		text, keyboard := welcomeScreen(lang)
+		if err := h.sendScreen(chatID, text, keyboard); err != nil {
 			log.Printf("Ошибка отправки приветственного сообщения: %v", err)
 		}
 		return
 	}
 
-	trialEnd := user.SubscriptionEndDate.Time
-	daysRemaining := int(trialEnd.Sub(time.Now()).Hours() / 24)
-	var text string
-	if user.IsTrial {
-		text = fmt.Sprintf("Количество оставшихся дней до окончания тестового периода: %d\n\n"+
-			"Вы можете оплатить подписку, оплаченный период добавится к текущему количеству оставшихся дней.", daysRemaining+1)
-	}
-
-	if !user.IsTrial {
-		text = fmt.Sprintf("Количество оставшихся дней до окончания подписки: %d\n\n", daysRemaining+1)
-	}
-
-	if !user.IsActive {
-		text = "Оплатите подписку, чтобы продолжить пользоваться сервисом."
-	}
-
-	if user.IsFriend {
-		text = "Ты пользуешься сервисом бесплатно!"
-	}
-
-	// Создаем inline-кнопки для различных платформ
-	buttonPay := tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить", "pay_method")
-	buttonConfigs := tgbotapi.NewInlineKeyboardButtonData("📶 Мои конфиги", "get_config")
-	buttonSupport := tgbotapi.NewInlineKeyboardButtonData("🆘 Написать в поддержку", "get_support")
-	buttonGuide := tgbotapi.NewInlineKeyboardButtonData("⚙️ Инструкция использования", "get_guide")
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(buttonPay),
-		tgbotapi.NewInlineKeyboardRow(buttonConfigs),
-		tgbotapi.NewInlineKeyboardRow(buttonSupport),
-		tgbotapi.NewInlineKeyboardRow(buttonGuide),
-	)
+	text, keyboard := mainMenu(user)
 
 	// Отправляем сообщение о пробном периоде с кнопками
-	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ReplyMarkup = keyboard
-	if _, err := h.Bot.Send(msg); err != nil {
+	if err := h.sendScreen(chatID, text, keyboard); err != nil {
 		log.Printf("Ошибка отправки сообщения о пробном периоде: %v", err)
 	}
 }
 
-func (h *BotHandler) handleCallbackQuery(callback *tgbotapi.CallbackQuery) {
-	log.Printf("Обработка callback: %s", callback.Data) // Лог для отладки
-	switch callback.Data {
-	case "get_started":
-		h.SendSubscriptionInfo(callback)
-		configUser := h.DB.GetUserConfig(callback.Message.Chat.ID, 1)
-		if configUser == "" {
-			username := fmt.Sprintf("%d_device1", callback.Message.Chat.ID)
-
-			cfg, err := config.LoadConfig()
-			if err != nil {
-				log.Printf("Ошибка загрузки конфигурации: %v", err)
-				msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Ошибка загрузки конфигурации.")
-				if _, err := h.Bot.Send(msg); err != nil {
-					log.Printf("Ошибка отправки сообщения: %v", err)
-				}
-				return
-			}
-
-			// Отправляем запрос на создание пользователя в Marzban
-			userResp, err := marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
-			if err != nil {
-				log.Printf("Получаем новый токен")
-				// Получаем новый токен
-				newAPIKey, err := marzban.GetAPIKey(cfg.Marzban.APIURL, cfg.Marzban.Username, cfg.Marzban.Password)
-				if err != nil {
-					log.Printf("Не удалось получить новый токен: %v", err)
-					msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Произошла ошибка при создании VPN-конфигурации.")
-					h.Bot.Send(msg)
-					return
-				}
-
-				err = marzban.UpdateAPIKey("configs/config.yaml", newAPIKey)
-				if err != nil {
-					log.Printf("Не удалось обновить конфиг: %v", err)
-					msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Произошла ошибка при обновлении конфигурации.")
-					h.Bot.Send(msg)
-					return
-				}
-
-				// Повторяем запрос CreateUser
-				cfg.Marzban.APIKey = newAPIKey // Обновляем APIKey в памяти
-				userResp, err = marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
-				if err != nil {
-					log.Printf("Ошибка создания пользователя даже после обновления токена: %v", err)
-					msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Произошла ошибка при создании VPN-конфигурации.")
-					h.Bot.Send(msg)
-					return
-				}
-			}
-
-			if !userResp.Success {
-				log.Printf("Не удалось создать пользователя в Marzban для %d: %s", callback.Message.Chat.ID, userResp.Message)
-				msg := tgbotapi.NewMessage(callback.Message.Chat.ID, fmt.Sprintf("Ошибка создания конфигурации: %s", userResp.Message))
-				if _, err := h.Bot.Send(msg); err != nil {
-					log.Printf("Ошибка отправки сообщения: %v", err)
-				}
-				return
-			}
+// handleStarted создаёт первое устройство пользователя и показывает его
+// конфиг с выбором инструкции.
+func (h *BotHandler) handleStarted(ctx context.Context, req *callbackRequest) error {
+	h.SendSubscriptionInfo(req.Query)
+	devices, err := h.DB.GetUserDevices(ctx, req.ChatID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройств: %w", err)
+	}
 
-			// Сохраняем конфиг в базе данных
-			err = h.DB.UpdateUserConfig(callback.Message.Chat.ID, 1, userResp.Message)
-			if err != nil {
-				log.Printf("Ошибка обновления конфига в базе для пользователя %d: %v", callback.Message.Chat.ID, err)
-				msg := tgbotapi.NewMessage(callback.Message.Chat.ID, "Произошла ошибка при сохранении конфигурации.")
-				if _, err := h.Bot.Send(msg); err != nil {
-					log.Printf("Ошибка отправки сообщения: %v", err)
-				}
-				return
-			}
-			configUser = h.DB.GetUserConfig(callback.Message.Chat.ID, 1)
+	var device *database.Device
+	if len(devices) > 0 {
+		device = &devices[0]
+	} else {
+		// Создаем первое устройство пользователя
+		device, err = h.addDevice(ctx, req.ChatID, 1)
+		if err != nil {
+			h.sendText(req.ChatID, req.T("started.failed"))
+			return fmt.Errorf("ошибка создания устройства: %w", err)
 		}
+	}
 
-		// Информация о сервисе
-		guideText := "Гайд по установке\n\n" +
-			"Выберите операционную систему\n\n" +
-			"Вы увидите подробную инструкцию по настройке со ссылкой на скачивание приложения\n\n" +
-			"Текущий сервер подключения:\n" +
-			"🇵🇱 Польша\n\n" +
-			"🟢 Нажмите на данный конфиг и он скопируется автоматически:\n" +
-			"```\n" + configUser + "\n```"
-
-		// Создаем inline-кнопку
-		buttonIOS := tgbotapi.NewInlineKeyboardButtonData("📱 iOS", "get_ios_guide")
-		buttonAndroid := tgbotapi.NewInlineKeyboardButtonData("📱 Android", "get_android_guide")
-		buttonWindows := tgbotapi.NewInlineKeyboardButtonData("🖥 Windows", "get_windows_guide")
-		buttonMac := tgbotapi.NewInlineKeyboardButtonData("🖥 MacOS", "get_mac_guide")
-		buttonMain := tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(buttonIOS, buttonAndroid),
-			tgbotapi.NewInlineKeyboardRow(buttonMac, buttonWindows),
-			tgbotapi.NewInlineKeyboardRow(buttonMain),
-		)
-
-		// Отправляем сообщение с кнопкой
-		editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-			callback.Message.Chat.ID,
-			callback.Message.MessageID,
-			guideText,
-			keyboard,
-		)
-
-		editMsg.ParseMode = "MarkdownV2"
-
-		if _, err := h.Bot.Send(editMsg); err != nil {
-			log.Printf("Ошибка отправки приветственного сообщения: %v", err)
-		}
+	text, keyboard := startedScreen(req.Lang, *device)
 
-		// Отправляем ответ на callback
-		callbackResp := tgbotapi.NewCallback(callback.ID, "Вы начали пользоваться сервисом!")
-		if _, err := h.Bot.Request(callbackResp); err != nil {
-			log.Printf("Ошибка отправки ответа на CallbackQuery: %v", err)
-		}
-	case "get_main":
-		user := h.DB.GetUserByID(callback.Message.Chat.ID)
-		if user == nil {
-			log.Printf("Ошибка ошибка получения пользователя")
-			return
-		}
+	req.Answer(req.T("started.answer"))
+	return h.editScreen(req, text, keyboard)
+}
 
-		trialEnd := user.SubscriptionEndDate.Time
-		daysRemaining := int(trialEnd.Sub(time.Now()).Hours() / 24)
-		var text string
-		if user.IsTrial {
-			text = fmt.Sprintf("Количество оставшихся дней до окончания тестового периода: %d\n\n"+
-				"Вы можете оплатить подписку, оплаченный период добавится к текущему количеству оставшихся дней.", daysRemaining+1)
-		}
+// handleMainMenu показывает главное меню. Переход в меню прерывает диалог,
+// в котором бот ждал ввода.
+func (h *BotHandler) handleMainMenu(ctx context.Context, req *callbackRequest) error {
+	h.clearState(ctx, req.ChatID)
+	text, keyboard := mainMenu(req.User)
+	req.Answer(req.T("menu.opened"))
+	return h.editScreen(req, text, keyboard)
+}
 
-		if !user.IsTrial {
-			text = fmt.Sprintf("Количество оставшихся дней до окончания подписки: %d\n\n", daysRemaining+1)
-		}
+func (h *BotHandler) handlePay(ctx context.Context, req *callbackRequest) error {
+	req.Answer(req.T("pay.unavailable"))
+	return nil
+}
 
-		if !user.IsActive {
-			text = "Оплатите подписку, чтобы продолжить пользоваться сервисом."
-		}
+// deviceLimit возвращает максимальное количество устройств для тарифа пользователя.
+func deviceLimit(cfg *config.Config, user database.User) int {
+	limit := cfg.App.DeviceLimits.Paid
+	switch {
+	case user.IsFriend:
+		limit = cfg.App.DeviceLimits.Friend
+	case user.IsTrial:
+		limit = cfg.App.DeviceLimits.Trial
+	}
 
-		if user.IsFriend {
-			text = "Ты пользуешься сервисом бесплатно!"
-		}
+	if limit <= 0 {
+		return defaultDeviceLimit
+	}
+	return limit
+}
 
-		// Создаем inline-кнопки для различных платформ
-		buttonPay := tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить", "pay_method")
-		buttonConfigs := tgbotapi.NewInlineKeyboardButtonData("📶 Мои конфиги", "get_config")
-		buttonSupport := tgbotapi.NewInlineKeyboardButtonData("🆘 Написать в поддержку", "get_support")
-		buttonGuide := tgbotapi.NewInlineKeyboardButtonData("⚙️ Инструкция использования", "get_guide")
-
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(buttonPay),
-			tgbotapi.NewInlineKeyboardRow(buttonConfigs),
-			tgbotapi.NewInlineKeyboardRow(buttonSupport),
-			tgbotapi.NewInlineKeyboardRow(buttonGuide),
-		)
-
-		// Отправляем сообщение о пробном периоде с кнопками
-		editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-			callback.Message.Chat.ID,
-			callback.Message.MessageID,
-			text,
-			keyboard,
-		)
-
-		if _, err := h.Bot.Send(editMsg); err != nil {
-			log.Printf("Ошибка отправки приветственного сообщения: %v", err)
-		}
+func (h *BotHandler) handleConfigList(ctx context.Context, req *callbackRequest) error {
+	user := req.User
 
-		// Отправляем ответ на callback
-		callbackResp := tgbotapi.NewCallback(callback.ID, "Выполнен переход в главное меню")
-		if _, err := h.Bot.Request(callbackResp); err != nil {
-			log.Printf("Ошибка отправки ответа на CallbackQuery: %v", err)
-		}
-	case "get_config":
-		user := h.DB.GetUserByID(callback.Message.Chat.ID)
-		if user == nil {
-			log.Printf("Ошибка получения пользователя")
-			return
-		}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
 
-		text := "📶 Мои конфиги\n\n" +
-			"Выберите существующий конфиг, либо создайте новый\\."
-
-		button1 := tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device1")
-		button2 := tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device2")
-		button3 := tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device3")
-		buttonMain := tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(button1),
-			tgbotapi.NewInlineKeyboardRow(button2),
-			tgbotapi.NewInlineKeyboardRow(button3),
-			tgbotapi.NewInlineKeyboardRow(buttonMain),
-		)
-
-		configs := []string{user.Config1, user.Config2, user.Config3}
-
-		if configs[0] != "" && configs[1] == "" && configs[2] == "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 1", "get_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+	devices, err := h.DB.GetUserDevices(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройств: %w", err)
+	}
 
-		if configs[0] != "" && configs[1] != "" && configs[2] == "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 1", "get_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 2", "get_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+	text, keyboard := configListScreen(req.Lang, user, devices, deviceLimit(cfg, user))
+	return h.editScreen(req, text, keyboard)
+}
 
-		if configs[0] != "" && configs[1] != "" && configs[2] != "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 1", "get_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 2", "get_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 3", "get_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+func (h *BotHandler) sendDeviceConfig(req *callbackRequest, deviceNumber int, device *database.Device) error {
+	text, keyboard := deviceScreen(req.Lang, deviceNumber, device)
+	return h.editScreen(req, text, keyboard)
+}
 
-		if configs[0] == "" && configs[1] != "" && configs[2] == "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 2", "get_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+func (h *BotHandler) handleDeviceCallback(ctx context.Context, req *callbackRequest) error {
+	deviceNumber := req.Int(0)
+	device, err := h.DB.GetDevice(ctx, req.ChatID, deviceNumber)
+	if errors.Is(err, database.ErrNotFound) {
+		return h.sendDeviceConfig(req, deviceNumber, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройства %d: %w", deviceNumber, err)
+	}
 
-		if configs[0] == "" && configs[1] != "" && configs[2] != "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 2", "get_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 3", "get_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+	return h.sendDeviceConfig(req, deviceNumber, &device)
+}
 
-		if configs[0] == "" && configs[1] == "" && configs[2] != "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 3", "get_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+func (h *BotHandler) handleAcceptDeleteDevice(ctx context.Context, req *callbackRequest) error {
+	deviceNumber := req.Int(0)
+	text, keyboard := confirmDeleteDeviceScreen(req.Lang, deviceNumber)
+	return h.editScreen(req, text, keyboard)
+}
 
-		if configs[0] != "" && configs[1] == "" && configs[2] != "" {
-			button1 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 1", "get_device1")
-			button2 = tgbotapi.NewInlineKeyboardButtonData("➕ Добавить конфиг", "new_device2")
-			button3 = tgbotapi.NewInlineKeyboardButtonData("📱 Открыть устройство 3", "get_device3")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(button1),
-				tgbotapi.NewInlineKeyboardRow(button2),
-				tgbotapi.NewInlineKeyboardRow(button3),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
-		}
+func (h *BotHandler) handleDeleteDevice(ctx context.Context, req *callbackRequest) error {
+	deviceNumber := req.Int(0)
+	device, err := h.DB.GetDevice(ctx, req.ChatID, deviceNumber)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("ошибка получения устройства %d: %w", deviceNumber, err)
+	}
 
-		if !user.IsActive {
-			text = "Оплатите подписку, чтобы продолжить пользоваться сервисом\\."
-			buttonPay := tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить", "pay_method")
-			keyboard = tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(buttonPay),
-				tgbotapi.NewInlineKeyboardRow(buttonMain),
-			)
+	var failed bool
+	if err == nil {
+		if err := deleteUserFromMarzban(device.MarzbanUsername); err != nil {
+			log.Printf("Ошибка удаления пользователя из Marzban: %v", err)
+			failed = true
+		} else if err := h.DB.DeleteDevice(ctx, device.ID); err != nil {
+			log.Printf("Ошибка удаления устройства %d из базы: %v", device.ID, err)
+		} else {
+			h.recordEvent(ctx, database.EventDeviceDeleted, database.ActorUser(device.UserID), device.UserID, map[string]interface{}{
+				"device": device.MarzbanUsername,
+			})
 		}
+	}
 
-		editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-			callback.Message.Chat.ID,
-			callback.Message.MessageID,
-			text,
-			keyboard,
-		)
+	text, keyboard := deviceDeletedScreen(req.Lang, deviceNumber, failed)
+	return h.editScreen(req, text, keyboard)
+}
 
-		editMsg.ParseMode = "MarkdownV2"
+// handleRevokeDevice перевыпускает ключ устройства: старая ссылка
+// отзывается в Marzban, новая сохраняется в базе и показывается пользователю.
+func (h *BotHandler) handleRevokeDevice(ctx context.Context, req *callbackRequest) error {
+	userID := req.ChatID
+	deviceNumber := req.Int(0)
 
-		if _, err := h.Bot.Send(editMsg); err != nil {
-			log.Printf("Ошибка отправки меню с конфигами: %v", err)
-		}
+	if !req.User.IsActive {
+		req.Answer(req.T("subscription.expired"))
+		return nil
+	}
 
-		// Отправляем ответ на callback
-		callbackResp := tgbotapi.NewCallback(callback.ID, "Ответ готов!")
-		if _, err := h.Bot.Request(callbackResp); err != nil {
-			log.Printf("Ошибка отправки ответа на CallbackQuery: %v", err)
-		}
-	case "get_device1":
-		h.handleDeviceCallback(callback, 1)
-	case "get_device2":
-		h.handleDeviceCallback(callback, 2)
-	case "get_device3":
-		h.handleDeviceCallback(callback, 3)
-	case "accept_delete_device1":
-		h.handleAcceptDeleteDevice(callback, 1)
-	case "accept_delete_device2":
-		h.handleAcceptDeleteDevice(callback, 2)
-	case "accept_delete_device3":
-		h.handleAcceptDeleteDevice(callback, 3)
-	case "delete_device1":
-		h.handleDeleteDevice(callback, 1)
-	case "delete_device2":
-		h.handleDeleteDevice(callback, 2)
-	case "delete_device3":
-		h.handleDeleteDevice(callback, 3)
-	case "new_device1":
-		h.handleNewDevice(callback, 1)
-	case "new_device2":
-		h.handleNewDevice(callback, 2)
-	case "new_device3":
-		h.handleNewDevice(callback, 3)
-	default:
-		log.Printf("Неизвестное действие: %s", callback.Data)
+	device, err := h.DB.GetDevice(ctx, userID, deviceNumber)
+	if errors.Is(err, database.ErrNotFound) {
+		return h.sendDeviceConfig(req, deviceNumber, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройства %d: %w", deviceNumber, err)
 	}
-}
 
-func (h *BotHandler) sendDeviceConfig(callback *tgbotapi.CallbackQuery, deviceNumber int, userConfig string) {
-	var text string
-	var keyboard tgbotapi.InlineKeyboardMarkup
-
-	if userConfig == "" {
-		text = fmt.Sprintf("📱 Устройство %d\n\nУ вас нет конфига для этого устройства\\.", deviceNumber)
-		buttonPay := tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить", "pay_method")
-		buttonMain := tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(buttonPay),
-			tgbotapi.NewInlineKeyboardRow(buttonMain),
-		)
-	} else {
-		text = fmt.Sprintf(
-			"📱 Устройство %d\n\nТекущий сервер подключения:\n🇵🇱 Польша\n\n🟢 Нажмите на данный конфиг и он скопируется автоматически:\n```\n%s\n```",
-			deviceNumber,
-			userConfig,
-		)
-
-		buttonDelete := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("❌ Удалить конфиг %d", deviceNumber), fmt.Sprintf("accept_delete_device%d", deviceNumber))
-		buttonBack := tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "get_config")
-		buttonMain := tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")
-		keyboard = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(buttonDelete),
-			tgbotapi.NewInlineKeyboardRow(buttonBack),
-			tgbotapi.NewInlineKeyboardRow(buttonMain),
-		)
-	}
-
-	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-		callback.Message.Chat.ID,
-		callback.Message.MessageID,
-		text,
-		keyboard,
-	)
-	editMsg.ParseMode = "MarkdownV2"
-
-	if _, err := h.Bot.Send(editMsg); err != nil {
-		log.Printf("Ошибка отправки сообщения для устройства %d: %v", deviceNumber, err)
-	}
-
-	// Ответ на callback
-	callbackResp := tgbotapi.NewCallback(callback.ID, "Ответ готов!")
-	if _, err := h.Bot.Request(callbackResp); err != nil {
-		log.Printf("Ошибка отправки ответа на CallbackQuery: %v", err)
+	userResp, err := revokeUserMarzban(device.MarzbanUsername)
+	if err != nil {
+		req.Answer(req.T("device.revoke_failed"))
+		return fmt.Errorf("ошибка перевыпуска ключа: %w", err)
 	}
-}
 
-func (h *BotHandler) handleDeviceCallback(callback *tgbotapi.CallbackQuery, deviceNumber int) {
-	user := h.DB.GetUserByID(callback.Message.Chat.ID)
-	if user == nil {
-		log.Printf("Ошибка получения пользователя")
-		return
+	// Старая ссылка в Marzban уже не работает, поэтому новая сохраняется
+	// до любых других шагов
+	if err := h.DB.UpdateDeviceLink(ctx, device.ID, userResp.Message, userResp.SubscriptionURL); err != nil {
+		return fmt.Errorf("ошибка сохранения нового ключа: %w", err)
 	}
+	device.Link = userResp.Message
+	device.SubscriptionURL = userResp.SubscriptionURL
+	h.recordEvent(ctx, database.EventDeviceRevoked, database.ActorUser(userID), userID, map[string]interface{}{
+		"device": device.MarzbanUsername,
+	})
 
-	var userConfig string
-	switch deviceNumber {
-	case 1:
-		userConfig = user.Config1
-	case 2:
-		userConfig = user.Config2
-	case 3:
-		userConfig = user.Config3
-	default:
-		log.Printf("Некорректный номер устройства: %d", deviceNumber)
-		return
+	// Конфиг мог быть отключен контролем устройств: с новым ключом
+	// старые подключения уже не работают, поэтому его можно включить.
+	// Если включить не удалось, новый ключ всё равно показывается, а
+	// повторный перевыпуск снова попробует включить конфиг
+	if err := setUserStatusMarzban(device.MarzbanUsername, "active"); err != nil {
+		logWithLocation("Ошибка включения конфига %s после перевыпуска: %v", device.MarzbanUsername, err)
+		h.SendNotificationToChannel(fmt.Sprintf("Конфиг %s перевыпущен, но не включен: %v", device.MarzbanUsername, err))
+		req.Answer(req.T("device.enable_failed"))
+		return h.sendDeviceConfig(req, deviceNumber, &device)
 	}
 
-	h.sendDeviceConfig(callback, deviceNumber, userConfig)
+	if err := h.DB.UpdateDeviceStatus(ctx, device.ID, database.DeviceStatusActive); err != nil {
+		log.Printf("Ошибка обновления статуса устройства %d: %v", device.ID, err)
+	}
+	device.Status = database.DeviceStatusActive
+	return h.sendDeviceConfig(req, deviceNumber, &device)
 }
 
-func (h *BotHandler) handleAcceptDeleteDevice(callback *tgbotapi.CallbackQuery, deviceNumber int) {
-	text := fmt.Sprintf("📱 Устройство %d\n\nВы уверены, что хотите удалить данный конфиг\\?", deviceNumber)
-	buttonAccept := tgbotapi.NewInlineKeyboardButtonData("✅ Да", fmt.Sprintf("delete_device%d", deviceNumber))
-	buttonCancel := tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("get_device%d", deviceNumber))
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(buttonAccept, buttonCancel),
-	)
-
-	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-		callback.Message.Chat.ID,
-		callback.Message.MessageID,
-		text,
-		keyboard,
-	)
-
-	editMsg.ParseMode = "MarkdownV2"
-
-	if _, err := h.Bot.Send(editMsg); err != nil {
-		log.Printf("Ошибка отправки меню с конфигами: %v", err)
+func deleteUserFromMarzban(username string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
-	// Отправляем ответ на callback
-	callbackResp := tgbotapi.NewCallback(callback.ID, "Ответ готов!")
-	if _, err := h.Bot.Request(callbackResp); err != nil {
-		log.Printf("Ошибка отправки ответа на CallbackQuery: %v", err)
+	err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return err
+		}
+		err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
 	}
+	if errors.Is(err, marzban.ErrNotFound) {
+		// Пользователя уже нет в панели — считаем удаление успешным
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка удаления пользователя %s: %w", username, err)
+	}
+	return nil
 }
 
-func (h *BotHandler) handleDeleteDevice(callback *tgbotapi.CallbackQuery, deviceNumber int) {
-	user := h.DB.GetUserByID(callback.Message.Chat.ID)
-	if user == nil {
-		log.Printf("Ошибка получения пользователя")
-		return
+// refreshAPIKey получает новый токен Marzban, сохраняет его в конфиг
+// и обновляет значение в памяти.
+func refreshAPIKey(cfg *config.Config) error {
+	newAPIKey, err := marzban.GetAPIKey(cfg.Marzban.APIURL, cfg.Marzban.Username, cfg.Marzban.Password)
+	if err != nil {
+		return fmt.Errorf("не удалось обновить токен: %w", err)
 	}
-
-	var userConfig string
-	switch deviceNumber {
-	case 1:
-		userConfig = user.Config1
-	case 2:
-		userConfig = user.Config2
-	case 3:
-		userConfig = user.Config3
-	default:
-		log.Printf("Неверный номер устройства: %d", deviceNumber)
-		return
+	if err = marzban.UpdateAPIKey("configs/config.yaml", newAPIKey); err != nil {
+		return fmt.Errorf("ошибка обновления конфигурации: %w", err)
 	}
 
-	text := fmt.Sprintf("📱 Устройство %d\n\nКонфиг для этого устройства удален\\.", deviceNumber)
-	buttonBack := tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "get_config")
-	buttonMain := tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(buttonBack),
-		tgbotapi.NewInlineKeyboardRow(buttonMain),
-	)
+	cfg.Marzban.APIKey = newAPIKey
+	return nil
+}
 
-	if userConfig != "" {
-		if err := deleteUserFromMarzban(user.ID, deviceNumber); err != nil {
-			log.Printf("Ошибка удаления пользователя из Marzban: %v", err)
-			text = fmt.Sprintf("📱 Устройство %d\n\nНе удалось удалить конфиг, попробуйте позже\\.", deviceNumber)
-		} else {
-			h.DB.UpdateUserConfig(user.ID, deviceNumber, "")
-		}
+func revokeUserMarzban(username string) (*marzban.UserResponse, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
-	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-		callback.Message.Chat.ID,
-		callback.Message.MessageID,
-		text,
-		keyboard,
-	)
-	editMsg.ParseMode = "MarkdownV2"
-
-	if _, err := h.Bot.Send(editMsg); err != nil {
-		log.Printf("Ошибка отправки сообщения: %v", err)
+	userResp, err := marzban.RevokeSubscription(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return nil, err
+		}
+		userResp, err = marzban.RevokeSubscription(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
 	}
-
-	callbackResp := tgbotapi.NewCallback(callback.ID, "Ответ готов!")
-	if _, err := h.Bot.Request(callbackResp); err != nil {
-		log.Printf("Ошибка отправки CallbackQuery: %v", err)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отзыва подписки %s: %w", username, err)
 	}
+	return userResp, nil
 }
 
-func deleteUserFromMarzban(userID int64, deviceNumber int) error {
+// resetTrafficMarzban обнуляет использованный трафик пользователя Marzban.
+func resetTrafficMarzban(username string) error {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
-	username := fmt.Sprintf("%d_device%d", userID, deviceNumber)
-	err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
-	if err != nil {
-		newAPIKey, err := marzban.GetAPIKey(cfg.Marzban.APIURL, cfg.Marzban.Username, cfg.Marzban.Password)
-		if err != nil {
-			return fmt.Errorf("не удалось обновить токен: %w", err)
-		}
-		if err = marzban.UpdateAPIKey("configs/config.yaml", newAPIKey); err != nil {
-			return fmt.Errorf("ошибка обновления конфигурации: %w", err)
-		}
-
-		cfg.Marzban.APIKey = newAPIKey
-		if err = marzban.DeleteUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username); err != nil {
-			return fmt.Errorf("ошибка удаления после обновления токена: %w", err)
+	err = marzban.ResetUserTraffic(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return err
 		}
+		err = marzban.ResetUserTraffic(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка сброса трафика %s: %w", username, err)
 	}
 	return nil
 }
 
-func (h *BotHandler) handleNewDevice(callback *tgbotapi.CallbackQuery, deviceNumber int) {
-	userID := callback.Message.Chat.ID
-	user := h.DB.GetUserByID(userID)
+func (h *BotHandler) handleNewDevice(ctx context.Context, req *callbackRequest) error {
+	userID := req.ChatID
+	user := req.User
 
 	if !user.IsActive {
-		callbackResp := tgbotapi.NewCallback(callback.ID, "Срок подписки истек!")
-		if _, err := h.Bot.Request(callbackResp); err != nil {
-			log.Printf("Ошибка отправки CallbackQuery: %v", err)
-		}
-		return
+		req.Answer(req.T("subscription.expired"))
+		return nil
 	}
 
-	configUser := h.DB.GetUserConfig(userID, deviceNumber)
-	if configUser != "" {
-		log.Printf("Конфиг уже существует")
-		return
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
-	userResp, err := createUserMarzban(userID, deviceNumber)
+	devices, err := h.DB.GetUserDevices(ctx, userID)
 	if err != nil {
-		log.Printf("Ошибка создания пользователя %v", err)
-		return
+		return fmt.Errorf("ошибка получения устройств: %w", err)
 	}
-
-	// Сохраняем конфигурацию в базе данных
-	if err := h.DB.UpdateUserConfig(userID, deviceNumber, userResp.Message); err != nil {
-		log.Printf("Ошибка создания пользователя %v", err)
-		return
+	if len(devices) >= deviceLimit(cfg, user) {
+		req.Answer(req.T("device.limit"))
+		return nil
 	}
 
-	// Формируем текст сообщения
-	text := fmt.Sprintf(
-		"📱 Устройство %d\n\nТекущий сервер подключения:\n🇵🇱 Польша\n\n🟢 Нажмите на данный конфиг и он скопируется автоматически:\n```\n%s\n```",
-		deviceNumber, userResp.Message,
-	)
-
-	// Создаём клавиатуру
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Удалить конфиг", fmt.Sprintf("accept_delete_device%d", deviceNumber))),
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("◀️ Назад", "get_config")),
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏡 В главное меню", "get_main")),
-	)
-
-	// Отправляем сообщение с конфигом
-	editMsg := tgbotapi.NewEditMessageTextAndMarkup(
-		callback.Message.Chat.ID,
-		callback.Message.MessageID,
-		text,
-		keyboard,
-	)
-	editMsg.ParseMode = "MarkdownV2"
-
-	if _, err := h.Bot.Send(editMsg); err != nil {
-		log.Printf("Ошибка отправки сообщения: %v", err)
+	slot, err := h.DB.FreeDeviceSlot(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка выбора слота: %w", err)
 	}
 
-	callbackResp := tgbotapi.NewCallback(callback.ID, "Ответ готов!")
-	if _, err := h.Bot.Request(callbackResp); err != nil {
-		log.Printf("Ошибка отправки CallbackQuery: %v", err)
+	device, err := h.addDevice(ctx, userID, slot)
+	if err != nil {
+		return err
 	}
+
+	return h.sendDeviceConfig(req, slot, device)
 }
 
-func createUserMarzban(userID int64, deviceNumber int) (*marzban.UserResponse, error) {
+// addDevice создает пользователя Marzban для слота и сохраняет устройство в базе.
+// Лимит устройств и активность подписки проверяются при сохранении
+// в одной транзакции; если проверка не прошла, пользователь Marzban удаляется.
+func (h *BotHandler) addDevice(ctx context.Context, userID int64, slot int) (*database.Device, error) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		return nil, fmt.Errorf("Ошибка загрузки конфигурации: %w", err)
+		return nil, fmt.Errorf("ошибка загрузки конфигурации: %w", err)
 	}
 
-	username := fmt.Sprintf("%d_device%d", userID, deviceNumber)
-	userResp, err := marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	user, err := h.DB.GetUserByID(ctx, userID)
 	if err != nil {
-		newAPIKey, err := marzban.GetAPIKey(cfg.Marzban.APIURL, cfg.Marzban.Username, cfg.Marzban.Password)
-		if err != nil {
-			return nil, fmt.Errorf("не удалось обновить токен: %w", err)
-		}
-		if err = marzban.UpdateAPIKey("configs/config.yaml", newAPIKey); err != nil {
-			return nil, fmt.Errorf("ошибка обновления конфигурации: %w", err)
+		return nil, fmt.Errorf("ошибка получения пользователя %d: %w", userID, err)
+	}
+
+	username := fmt.Sprintf("%d_device%d", userID, slot)
+	userResp, err := createUserMarzban(cfg, username)
+	if err != nil {
+		return nil, err
+	}
+
+	inbounds := cfg.Marzban.Inbounds
+	if len(inbounds) == 0 {
+		inbounds = marzban.DefaultInbounds
+	}
+	protocols := make([]string, 0, len(inbounds))
+	for protocol := range inbounds {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	device := &database.Device{
+		UserID:          userID,
+		Slot:            slot,
+		MarzbanUsername: username,
+		Location:        defaultLocation,
+		Protocol:        strings.Join(protocols, ","),
+		Link:            userResp.Message,
+		SubscriptionURL: userResp.SubscriptionURL,
+	}
+	if err := h.DB.AddDevice(ctx, device, deviceLimit(cfg, user)); err != nil {
+		if errors.Is(err, database.ErrDeviceLimit) || errors.Is(err, database.ErrUserInactive) {
+			if delErr := deleteUserFromMarzban(username); delErr != nil {
+				log.Printf("Ошибка удаления лишнего пользователя %s из Marzban: %v", username, delErr)
+			}
 		}
+		return nil, fmt.Errorf("ошибка сохранения устройства %s: %w", username, err)
+	}
+	h.recordEvent(ctx, database.EventDeviceCreated, database.ActorUser(userID), userID, map[string]interface{}{
+		"device":   username,
+		"protocol": device.Protocol,
+	})
+	return device, nil
+}
 
-		cfg.Marzban.APIKey = newAPIKey
-		userResp, err = marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
-		if err != nil {
-			return nil, fmt.Errorf("ошибка удаления после обновления токена: %w", err)
+func createUserMarzban(cfg *config.Config, username string) (*marzban.UserResponse, error) {
+	userResp, err := marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username, cfg.Marzban.Inbounds)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return nil, err
 		}
+		userResp, err = marzban.CreateUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username, cfg.Marzban.Inbounds)
+	}
+	if errors.Is(err, marzban.ErrUserExists) {
+		// Пользователь уже есть в панели (например, после сбоя сохранения) —
+		// восстанавливаем его ссылку вместо повторного создания
+		log.Printf("Пользователь %s уже существует в Marzban, восстанавливаем ссылку", username)
+		userResp, err = marzban.GetUser(cfg.Marzban.APIURL, cfg.Marzban.APIKey, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания пользователя %s: %w", username, err)
 	}
 	return userResp, nil
 }
@@ -911,14 +699,24 @@ func createUserMarzban(userID int64, deviceNumber int) (*marzban.UserResponse, e
 func (h *BotHandler) HandleUpdate(update tgbotapi.Update) {
 	// Логируем все обновления для отладки
 	log.Printf("Обновление получено: %+v", update)
+	ctx := context.Background()
+
+	if update.MyChatMember != nil {
+		h.handleMyChatMember(ctx, update.MyChatMember)
+		return
+	}
+
+	if from := update.SentFrom(); from != nil {
+		h.touchUser(ctx, from)
+	}
 
 	if update.CallbackQuery != nil {
 		log.Printf("Получен callback: %s", update.CallbackQuery.Data)
-		h.handleCallbackQuery(update.CallbackQuery)
+		h.handleCallbackQuery(ctx, update.CallbackQuery)
 		return
 	}
 
 	if update.Message != nil {
-		h.HandleMessage(update.Message)
+		h.HandleMessage(ctx, update.Message)
 	}
 }
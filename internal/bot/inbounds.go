@@ -0,0 +1,131 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"go-vpn-bot/internal/marzban"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ValidateMarzbanInbounds проверяет при запуске, что протоколы и теги
+// инбаундов из конфигурации существуют в панели Marzban. Без этой проверки
+// переименованный инбаунд обнаруживается только по ошибкам CreateUser.
+func ValidateMarzbanInbounds(cfg *config.Config) error {
+	inbounds, err := getInboundsMarzban(cfg)
+	if err != nil {
+		return fmt.Errorf("не удалось получить список инбаундов: %w", err)
+	}
+
+	configured := cfg.Marzban.Inbounds
+	if len(configured) == 0 {
+		configured = marzban.DefaultInbounds
+	}
+
+	if err := marzban.ValidateInbounds(inbounds, configured); err != nil {
+		return err
+	}
+
+	log.Printf("Инбаунды Marzban проверены: %v", configured)
+	return nil
+}
+
+// handleInboundsCommand показывает администратору доступные инбаунды и ноды.
+func (h *BotHandler) handleInboundsCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	inbounds, err := getInboundsMarzban(cfg)
+	if err != nil {
+		logWithLocation("Ошибка получения инбаундов: %v", err)
+		h.sendText(message.Chat.ID, fmt.Sprintf("Не удалось получить инбаунды: %v", err))
+		return
+	}
+
+	nodes, err := marzban.GetNodes(cfg.Marzban.APIURL, cfg.Marzban.APIKey)
+	if err != nil {
+		logWithLocation("Ошибка получения нод: %v", err)
+		h.sendText(message.Chat.ID, fmt.Sprintf("Не удалось получить ноды: %v", err))
+		return
+	}
+
+	h.sendText(message.Chat.ID, formatInboundsAndNodes(inbounds, nodes, cfg.Marzban.Inbounds))
+}
+
+func formatInboundsAndNodes(inbounds map[string][]marzban.Inbound, nodes []marzban.Node, configured map[string][]string) string {
+	if len(configured) == 0 {
+		configured = marzban.DefaultInbounds
+	}
+
+	inUse := make(map[string]bool)
+	for protocol, tags := range configured {
+		for _, tag := range tags {
+			inUse[protocol+"/"+tag] = true
+		}
+	}
+
+	protocols := make([]string, 0, len(inbounds))
+	for protocol := range inbounds {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	var b strings.Builder
+	b.WriteString("📡 Инбаунды\n")
+	for _, protocol := range protocols {
+		for _, inbound := range inbounds[protocol] {
+			mark := "▫️"
+			if inUse[protocol+"/"+inbound.Tag] {
+				mark = "✅"
+			}
+			fmt.Fprintf(&b, "%s %s — %s, %s, порт %v\n", mark, inbound.Tag, protocol, inbound.Network, inbound.Port)
+		}
+	}
+
+	b.WriteString("\n🖥 Ноды\n")
+	if len(nodes) == 0 {
+		b.WriteString("Нет подключённых нод\n")
+	}
+	for _, node := range nodes {
+		status := "🟢"
+		if node.Status != "connected" {
+			status = "🔴"
+		}
+		fmt.Fprintf(&b, "%s %s (%s:%d) — %s", status, node.Name, node.Address, node.Port, node.Status)
+		if node.XrayVersion != nil {
+			fmt.Fprintf(&b, ", Xray %s", *node.XrayVersion)
+		}
+		if node.Message != nil && *node.Message != "" {
+			fmt.Fprintf(&b, "\n   %s", *node.Message)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func getInboundsMarzban(cfg *config.Config) (map[string][]marzban.Inbound, error) {
+	inbounds, err := marzban.GetInbounds(cfg.Marzban.APIURL, cfg.Marzban.APIKey)
+	if errors.Is(err, marzban.ErrUnauthorized) {
+		if err = refreshAPIKey(cfg); err != nil {
+			return nil, err
+		}
+		inbounds, err = marzban.GetInbounds(cfg.Marzban.APIURL, cfg.Marzban.APIKey)
+	}
+	return inbounds, err
+}
@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/marzban/marzbantest"
+
+	config "go-vpn-bot/configs"
+)
+
+// newTestHandler запускает поддельный Marzban и переходит во временный
+// каталог с configs/config.yaml, который указывает на него: обработчики
+// читают конфигурацию через config.LoadConfig() из рабочего каталога.
+// Поэтому тесты с ним нельзя запускать параллельно.
+func newTestHandler(t *testing.T) (*BotHandler, *marzbantest.Server) {
+	t.Helper()
+
+	srv := marzbantest.NewServer()
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "configs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf(`marzban:
+  api_url: %s
+  api_key: %s
+  username: %s
+  password: %s
+app:
+  device_limits:
+    trial: 3
+`, srv.URL, srv.Token(), srv.Username, srv.Password)
+	if err := os.WriteFile(filepath.Join(dir, "configs", "config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	store := database.NewMemoryStore()
+	if err := store.CreateUser(context.Background(), 1, 7); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return &BotHandler{DB: store}, srv
+}
+
+func TestAddDevice(t *testing.T) {
+	h, srv := newTestHandler(t)
+	ctx := context.Background()
+
+	device, err := h.addDevice(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("addDevice: %v", err)
+	}
+
+	u, ok := srv.User("1_device1")
+	if !ok {
+		t.Fatal("user was not created in Marzban")
+	}
+	if device.Link != u.Links[0] || device.SubscriptionURL != u.SubscriptionURL {
+		t.Errorf("device links = %q, %q, want %q, %q", device.Link, device.SubscriptionURL, u.Links[0], u.SubscriptionURL)
+	}
+
+	stored, err := h.DB.GetDevice(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("GetDevice: %v", err)
+	}
+	if stored.Link != u.Links[0] || stored.MarzbanUsername != "1_device1" {
+		t.Errorf("stored device = %+v", stored)
+	}
+}
+
+// TestAddDeviceRefreshesToken проверяет, что после 401 бот получает новый
+// токен, сохраняет его в конфигурацию и повторяет запрос.
+func TestAddDeviceRefreshesToken(t *testing.T) {
+	h, srv := newTestHandler(t)
+	srv.ExpireToken()
+
+	if _, err := h.addDevice(context.Background(), 1, 1); err != nil {
+		t.Fatalf("addDevice: %v", err)
+	}
+	if _, ok := srv.User("1_device1"); !ok {
+		t.Fatal("user was not created in Marzban")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Marzban.APIKey != srv.Token() {
+		t.Errorf("saved api_key = %q, want %q", cfg.Marzban.APIKey, srv.Token())
+	}
+}
+
+// TestAddDeviceRecoversExistingUser проверяет восстановление ссылки, если
+// пользователь уже есть в панели (409).
+func TestAddDeviceRecoversExistingUser(t *testing.T) {
+	h, srv := newTestHandler(t)
+	existing := srv.AddUser("1_device1")
+
+	device, err := h.addDevice(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("addDevice: %v", err)
+	}
+	if device.Link != existing.Links[0] {
+		t.Errorf("link = %q, want existing %q", device.Link, existing.Links[0])
+	}
+}
+
+func TestAddDeviceServerError(t *testing.T) {
+	h, srv := newTestHandler(t)
+	ctx := context.Background()
+	srv.FailNext(1, http.StatusInternalServerError)
+
+	if _, err := h.addDevice(ctx, 1, 1); err == nil {
+		t.Fatal("addDevice succeeded on 500")
+	}
+
+	devices, err := h.DB.GetUserDevices(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUserDevices: %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("devices = %+v, want none", devices)
+	}
+}
+
+// TestRevokeAndDeactivate проходит перевыпуск ключа и отключение
+// пользователя по истечении подписки на медленной панели.
+func TestRevokeAndDeactivate(t *testing.T) {
+	h, srv := newTestHandler(t)
+	ctx := context.Background()
+	srv.SetLatency(20 * time.Millisecond)
+
+	device, err := h.addDevice(ctx, 1, 1)
+	if err != nil {
+		t.Fatalf("addDevice: %v", err)
+	}
+
+	resp, err := revokeUserMarzban(device.MarzbanUsername)
+	if err != nil {
+		t.Fatalf("revokeUserMarzban: %v", err)
+	}
+	if resp.Message == device.Link {
+		t.Error("link did not change after revoke")
+	}
+	if u, _ := srv.User(device.MarzbanUsername); u.Links[0] != resp.Message {
+		t.Errorf("revoked link = %q, server has %q", resp.Message, u.Links[0])
+	}
+
+	if err := h.deactivateUser(ctx, 1); err != nil {
+		t.Fatalf("deactivateUser: %v", err)
+	}
+	if names := srv.Usernames(); len(names) != 0 {
+		t.Errorf("Marzban users after deactivation = %v, want none", names)
+	}
+	devices, err := h.DB.GetUserDevices(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUserDevices: %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("devices after deactivation = %+v, want none", devices)
+	}
+	user, err := h.DB.GetUserByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.IsActive {
+		t.Error("user is still active after deactivation")
+	}
+}
+
+// TestResetTraffic проверяет сброс трафика с обновлением токена и для
+// пользователя Marzban без ссылок.
+func TestResetTraffic(t *testing.T) {
+	_, srv := newTestHandler(t)
+	srv.AddUser("1_device1")
+	srv.SetUsedTraffic("1_device1", 1024)
+	srv.ClearLinks("1_device1")
+	srv.ExpireToken()
+
+	if err := resetTrafficMarzban("1_device1"); err != nil {
+		t.Fatalf("resetTrafficMarzban: %v", err)
+	}
+	if u, _ := srv.User("1_device1"); u.UsedTraffic != 0 {
+		t.Errorf("used traffic = %d, want 0", u.UsedTraffic)
+	}
+}
+
+// TestEraseUserPartialFailure проверяет, что при сбое панели из базы
+// удаляются только уже удалённые из Marzban устройства, а повтор
+// завершает удаление.
+func TestEraseUserPartialFailure(t *testing.T) {
+	h, srv := newTestHandler(t)
+	ctx := context.Background()
+
+	for slot := 1; slot <= 2; slot++ {
+		if _, err := h.addDevice(ctx, 1, slot); err != nil {
+			t.Fatalf("addDevice %d: %v", slot, err)
+		}
+	}
+
+	// Первый конфиг удаляется, на втором панель отвечает ошибкой
+	srv.FailNext(1, 0)
+	srv.FailNext(1, http.StatusInternalServerError)
+	if err := h.eraseUser(ctx, 1); err == nil {
+		t.Fatal("eraseUser succeeded on 500")
+	}
+
+	devices, err := h.DB.GetUserDevices(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUserDevices: %v", err)
+	}
+	if len(devices) != 1 || devices[0].Slot != 2 {
+		t.Errorf("devices after partial failure = %+v, want only slot 2", devices)
+	}
+	if user, err := h.DB.GetUserByID(ctx, 1); err != nil || user.DeletedAt.Valid {
+		t.Errorf("user after partial failure = %+v, %v", user, err)
+	}
+
+	if err := h.eraseUser(ctx, 1); err != nil {
+		t.Fatalf("eraseUser retry: %v", err)
+	}
+	if names := srv.Usernames(); len(names) != 0 {
+		t.Errorf("Marzban users after erase = %v, want none", names)
+	}
+	if user, err := h.DB.GetUserByID(ctx, 1); err != nil || !user.DeletedAt.Valid {
+		t.Errorf("user after erase = %+v, %v", user, err)
+	}
+}
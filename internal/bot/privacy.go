@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleMyDataCommand отправляет пользователю JSON-файл со всеми
+// данными, которые бот о нём хранит.
+func (h *BotHandler) handleMyDataCommand(ctx context.Context, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	lang := h.chatLanguage(ctx, chatID, message.From)
+
+	data, err := database.CollectUserData(ctx, h.DB, chatID)
+	if errors.Is(err, database.ErrNotFound) {
+		h.sendText(chatID, i18n.T(lang, "mydata.empty"))
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка выгрузки данных пользователя %d: %v", chatID, err)
+		h.sendText(chatID, i18n.T(lang, "mydata.failed"))
+		return
+	}
+
+	body, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		logWithLocation("Ошибка сериализации данных пользователя %d: %v", chatID, err)
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "mydata.json", Bytes: body})
+	doc.Caption = i18n.T(lang, "mydata.caption")
+	if _, err := h.Bot.Send(doc); err != nil {
+		log.Printf("Ошибка отправки выгрузки данных: %v", err)
+	}
+}
+
+// handleDeleteMeCommand запрашивает подтверждение удаления данных.
+func (h *BotHandler) handleDeleteMeCommand(ctx context.Context, message *tgbotapi.Message) {
+	lang := h.chatLanguage(ctx, message.Chat.ID, message.From)
+	text := i18n.T(lang, "deleteme.confirm")
+
+	buttonAccept := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "deleteme.accept"), actionDeleteMe)
+	buttonCancel := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.cancel"), actionMain)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonAccept, buttonCancel),
+	)
+	if _, err := h.Bot.Send(msg); err != nil {
+		log.Printf("Ошибка отправки сообщения: %v", err)
+	}
+}
+
+// handleConfirmDeleteMe удаляет пользователей Marzban и персональные данные.
+func (h *BotHandler) handleConfirmDeleteMe(ctx context.Context, req *callbackRequest) error {
+	userID := req.ChatID
+	text := req.T("deleteme.done")
+
+	err := h.eraseUser(ctx, userID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		text = req.T("mydata.empty")
+	case err != nil:
+		logWithLocation("Ошибка удаления данных пользователя %d: %v", userID, err)
+		text = req.T("deleteme.failed")
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(req.ChatID, req.MessageID, text)
+	_, err = h.Bot.Send(editMsg)
+	return err
+}
+
+// eraseUser удаляет конфиги пользователя из Marzban, а затем его данные из
+// базы. Если какой-то конфиг не удалось удалить из панели, из базы удаляются
+// только уже удалённые из панели устройства, а профиль остаётся: пользователь
+// может повторить /deleteme, удалённые конфиги при повторе не мешают.
+func (h *BotHandler) eraseUser(ctx context.Context, userID int64) error {
+	devices, err := h.DB.GetUserDevices(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		if err := deleteUserFromMarzban(device.MarzbanUsername); err != nil {
+			return err
+		}
+		if err := h.DB.DeleteDevice(ctx, device.ID); err != nil {
+			logWithLocation("Ошибка удаления устройства %d из базы: %v", device.ID, err)
+		}
+	}
+	return h.DB.EraseUser(ctx, userID)
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go-vpn-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func profileFromTelegram(from *tgbotapi.User) database.Profile {
+	return database.Profile{
+		UserID:       from.ID,
+		Username:     from.UserName,
+		FirstName:    from.FirstName,
+		LastName:     from.LastName,
+		LanguageCode: from.LanguageCode,
+	}
+}
+
+// touchUser обновляет профиль и время последнего обращения пользователя.
+// Незарегистрированные пользователи пропускаются: их профиль сохраняется
+// при создании в /start.
+func (h *BotHandler) touchUser(ctx context.Context, from *tgbotapi.User) {
+	err := h.DB.TouchUser(ctx, profileFromTelegram(from))
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		logWithLocation("Ошибка обновления профиля пользователя %d: %v", from.ID, err)
+	}
+}
+
+// handleMyChatMember отмечает, что пользователь заблокировал или
+// разблокировал бота в личном чате.
+func (h *BotHandler) handleMyChatMember(ctx context.Context, member *tgbotapi.ChatMemberUpdated) {
+	if !member.Chat.IsPrivate() {
+		return
+	}
+
+	blocked := member.NewChatMember.WasKicked()
+	err := h.DB.SetBlockedBot(ctx, member.Chat.ID, blocked)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		logWithLocation("Ошибка обновления статуса блокировки пользователя %d: %v", member.Chat.ID, err)
+	}
+}
+
+// isBlockedByUser распознаёт ошибку отправки пользователю, заблокировавшему бота.
+func isBlockedByUser(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && tgErr.Code == http.StatusForbidden
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-vpn-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrCodeSize — сторона картинки QR-кода в пикселях.
+const qrCodeSize = 512
+
+// deviceQRContent возвращает то, что кодируется в QR-код устройства:
+// ссылку конфига, а если её нет — ссылку подписки.
+func deviceQRContent(device database.Device) string {
+	if device.Link != "" {
+		return device.Link
+	}
+	return device.SubscriptionURL
+}
+
+// deviceQRCode рисует PNG с QR-кодом. Средний уровень коррекции ошибок
+// вмещает длинные ссылки и при этом нормально сканируется с экрана.
+func deviceQRCode(content string) ([]byte, error) {
+	png, err := qrcode.Encode(content, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания QR-кода: %w", err)
+	}
+	return png, nil
+}
+
+// handleDeviceQR отправляет QR-код конфига отдельным фото: "device_qr:<слот>".
+// Экран устройства остаётся на месте, чтобы ссылку можно было и скопировать.
+func (h *BotHandler) handleDeviceQR(ctx context.Context, req *callbackRequest) error {
+	deviceNumber := req.Int(0)
+	device, err := h.DB.GetDevice(ctx, req.ChatID, deviceNumber)
+	if errors.Is(err, database.ErrNotFound) {
+		return h.sendDeviceConfig(req, deviceNumber, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения устройства %d: %w", deviceNumber, err)
+	}
+
+	if device.Status == database.DeviceStatusDisabled {
+		req.Answer(req.T("device.qr_disabled"))
+		return nil
+	}
+
+	content := deviceQRContent(device)
+	if content == "" {
+		req.Answer(req.T("device.qr_failed"))
+		return fmt.Errorf("у устройства %d нет ссылки", device.ID)
+	}
+
+	png, err := deviceQRCode(content)
+	if err != nil {
+		req.Answer(req.T("device.qr_failed"))
+		return err
+	}
+
+	photo := tgbotapi.NewPhoto(req.ChatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("device%d.png", deviceNumber),
+		Bytes: png,
+	})
+	photo.Caption = req.T("device.qr_caption", deviceNumber, device.Location)
+	if _, err := h.Bot.Send(photo); err != nil {
+		return fmt.Errorf("ошибка отправки QR-кода: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"go-vpn-bot/internal/database"
+)
+
+func TestDeviceQRCode(t *testing.T) {
+	// Длинная ссылка vless с параметрами транспорта тоже должна помещаться
+	long := testLink + "&" + strings.Repeat("x", 500)
+	for _, content := range []string{testLink, testSubURL, long} {
+		data, err := deviceQRCode(content)
+		if err != nil {
+			t.Fatalf("deviceQRCode(%d bytes): %v", len(content), err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode PNG: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != qrCodeSize || b.Dy() != qrCodeSize {
+			t.Errorf("image size %dx%d, want %dx%d", b.Dx(), b.Dy(), qrCodeSize, qrCodeSize)
+		}
+	}
+}
+
+func TestDeviceQRContent(t *testing.T) {
+	tests := []struct {
+		device database.Device
+		want   string
+	}{
+		{database.Device{Link: testLink, SubscriptionURL: testSubURL}, testLink},
+		{database.Device{SubscriptionURL: testSubURL}, testSubURL},
+		{database.Device{}, ""},
+	}
+	for _, tt := range tests {
+		if got := deviceQRContent(tt.device); got != tt.want {
+			t.Errorf("deviceQRContent(%+v) = %q, want %q", tt.device, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Действия inline-кнопок. Данные кнопки имеют вид "действие:арг1:арг2",
+// см. callbackData.
+const (
+	actionStarted         = "get_started"
+	actionMain            = "get_main"
+	actionConfigs         = "get_config"
+	actionNewDevice       = "new_device"
+	actionDevice          = "get_device"
+	actionAskDeleteDevice = "accept_delete_device"
+	actionDeleteDevice    = "delete_device"
+	actionRevokeDevice    = "revoke_device"
+	actionDeviceQR        = "device_qr"
+	actionGuides          = "get_guide"
+	actionGuide           = "guide"
+	actionGuideStep       = "guide_step"
+	actionSupport         = "get_support"
+	actionDeleteMe        = "confirm_deleteme"
+	actionPay             = "pay_method"
+	actionCancel          = "cancel_input"
+	actionBroadcast       = "broadcast_send"
+	actionSettings        = "settings"
+	actionLanguage        = "set_lang"
+)
+
+// Ключи общих ответов на callback.
+const (
+	answerStale     = "callback.stale"
+	answerNoUser    = "callback.no_user"
+	answerErrorText = "error.generic"
+)
+
+// callbackArg — тип аргумента действия, проверяется роутером до вызова
+// обработчика.
+type callbackArg int
+
+const (
+	argString callbackArg = iota
+	// argInt — целое число больше нуля
+	argInt
+)
+
+// callbackRoute описывает обработчик действия.
+type callbackRoute struct {
+	args []callbackArg
+	// needUser — обработчику нужен зарегистрированный пользователь,
+	// роутер загружает его в req.User
+	needUser bool
+	handle   func(ctx context.Context, req *callbackRequest) error
+}
+
+// callbackRequest — разобранный callback, который получает обработчик.
+type callbackRequest struct {
+	Query     *tgbotapi.CallbackQuery
+	ChatID    int64
+	MessageID int
+	Action    string
+	Args      []string
+	// User загружается, если пользователь зарегистрирован; для маршрутов
+	// с needUser он есть всегда
+	User database.User
+	// Lang — язык интерфейса пользователя
+	Lang string
+
+	answer string
+}
+
+// Int и String возвращают аргумент, тип которого уже проверен роутером.
+func (r *callbackRequest) Int(i int) int {
+	n, _ := strconv.Atoi(r.Args[i])
+	return n
+}
+
+func (r *callbackRequest) String(i int) string {
+	return r.Args[i]
+}
+
+// T возвращает текст на языке пользователя.
+func (r *callbackRequest) T(key string, args ...interface{}) string {
+	return i18n.T(r.Lang, key, args...)
+}
+
+// Answer задаёт текст всплывающего ответа на callback. Ответ отправляет
+// роутер после обработчика, даже если тот завершился ошибкой.
+func (r *callbackRequest) Answer(text string) {
+	r.answer = text
+}
+
+// callbackData собирает данные кнопки из действия и аргументов.
+func callbackData(action string, args ...interface{}) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, action)
+	for _, arg := range args {
+		parts = append(parts, fmt.Sprint(arg))
+	}
+	return strings.Join(parts, ":")
+}
+
+// legacyGuideCallbacks — данные кнопок инструкций до перехода на вид
+// "действие:аргументы". Такие кнопки остаются в старых сообщениях чатов.
+var legacyGuideCallbacks = map[string]string{
+	"get_ios_guide":     callbackData(actionGuide, "ios"),
+	"get_android_guide": callbackData(actionGuide, "android"),
+	"get_mac_guide":     callbackData(actionGuide, "mac"),
+	"get_windows_guide": callbackData(actionGuide, "windows"),
+}
+
+// legacySlotActions — действия, к которым номер слота раньше приписывался
+// без разделителя: "get_device2", "delete_device1".
+var legacySlotActions = []string{
+	actionAskDeleteDevice,
+	actionDeleteDevice,
+	actionRevokeDevice,
+	actionDevice,
+}
+
+// legacyCallbackData переводит данные кнопки старого формата в текущий.
+// Данные нового формата возвращаются без изменений.
+func legacyCallbackData(data string) string {
+	if alias, ok := legacyGuideCallbacks[data]; ok {
+		return alias
+	}
+	if strings.Contains(data, ":") {
+		return data
+	}
+	for _, action := range legacySlotActions {
+		slot := strings.TrimPrefix(data, action)
+		if slot == data || slot == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(slot); err == nil && n > 0 {
+			return callbackData(action, n)
+		}
+	}
+	return data
+}
+
+// callbackRoutes возвращает таблицу обработчиков inline-кнопок.
+func (h *BotHandler) callbackRoutes() map[string]callbackRoute {
+	slot := []callbackArg{argInt}
+	return map[string]callbackRoute{
+		actionStarted:         {needUser: true, handle: h.handleStarted},
+		actionMain:            {needUser: true, handle: h.handleMainMenu},
+		actionConfigs:         {needUser: true, handle: h.handleConfigList},
+		actionNewDevice:       {needUser: true, handle: h.handleNewDevice},
+		actionDevice:          {args: slot, handle: h.handleDeviceCallback},
+		actionAskDeleteDevice: {args: slot, handle: h.handleAcceptDeleteDevice},
+		actionDeleteDevice:    {args: slot, handle: h.handleDeleteDevice},
+		actionRevokeDevice:    {args: slot, needUser: true, handle: h.handleRevokeDevice},
+		actionDeviceQR:        {args: slot, handle: h.handleDeviceQR},
+		actionGuides:          {handle: h.handleGuideMenu},
+		actionGuide:           {args: []callbackArg{argString}, handle: h.handleGuide},
+		actionGuideStep:       {args: []callbackArg{argString, argInt}, handle: h.handleGuideStep},
+		actionSupport:         {needUser: true, handle: h.handleSupport},
+		actionDeleteMe:        {handle: h.handleConfirmDeleteMe},
+		actionPay:             {handle: h.handlePay},
+		actionCancel:          {handle: h.handleCancelInput},
+		actionBroadcast:       {handle: h.handleBroadcastConfirm},
+		actionSettings:        {needUser: true, handle: h.handleSettings},
+		actionLanguage:        {args: []callbackArg{argString}, needUser: true, handle: h.handleSetLanguage},
+	}
+}
+
+// handleCallbackQuery разбирает данные кнопки и вызывает обработчик действия.
+// Ответ на callback отправляется всегда, в том числе после ошибки или паники
+// обработчика, чтобы у пользователя не зависали «часики» на кнопке.
+func (h *BotHandler) handleCallbackQuery(ctx context.Context, callback *tgbotapi.CallbackQuery) {
+	req := &callbackRequest{Query: callback}
+	defer func() {
+		if p := recover(); p != nil {
+			logWithLocation("Паника при обработке callback %q: %v\n%s", callback.Data, p, debug.Stack())
+			req.answer = req.T(answerErrorText)
+		}
+		h.answerCallback(callback, req.answer)
+	}()
+
+	if err := h.routeCallback(ctx, req); err != nil {
+		logWithLocation("Ошибка обработки callback %q пользователя %d: %v", callback.Data, req.ChatID, err)
+		if req.answer == "" {
+			req.answer = req.T(answerErrorText)
+		}
+	}
+}
+
+func (h *BotHandler) routeCallback(ctx context.Context, req *callbackRequest) error {
+	req.Lang = i18n.Default
+	if req.Query.From != nil {
+		req.Lang = i18n.Match(req.Query.From.LanguageCode)
+	}
+	if req.Query.Message == nil {
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+	req.ChatID = req.Query.Message.Chat.ID
+	req.MessageID = req.Query.Message.MessageID
+
+	// Пользователь нужен и для выбора языка ответа
+	user, err := h.DB.GetUserByID(ctx, req.ChatID)
+	registered := err == nil
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	if registered {
+		req.User = user
+		req.Lang = userLanguage(user)
+	}
+
+	parts := strings.Split(legacyCallbackData(req.Query.Data), ":")
+	req.Action, req.Args = parts[0], parts[1:]
+
+	route, ok := h.callbackRoutes()[req.Action]
+	if !ok || !validCallbackArgs(route.args, req.Args) {
+		logWithLocation("Неизвестное действие: %s", req.Query.Data)
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+
+	if route.needUser && !registered {
+		req.Answer(req.T(answerNoUser))
+		return nil
+	}
+
+	return route.handle(ctx, req)
+}
+
+func validCallbackArgs(want []callbackArg, args []string) bool {
+	if len(want) != len(args) {
+		return false
+	}
+	for i, kind := range want {
+		switch kind {
+		case argInt:
+			if n, err := strconv.Atoi(args[i]); err != nil || n <= 0 {
+				return false
+			}
+		case argString:
+			if args[i] == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (h *BotHandler) answerCallback(callback *tgbotapi.CallbackQuery, text string) {
+	if _, err := h.Bot.Request(tgbotapi.NewCallback(callback.ID, text)); err != nil {
+		logWithLocation("Ошибка отправки ответа на CallbackQuery: %v", err)
+	}
+}
+
+// editScreen заменяет текст и кнопки сообщения, на котором нажата кнопка.
+// Текст экрана передаётся в разметке MarkdownV2 (см. screens.go).
+func (h *BotHandler) editScreen(req *callbackRequest, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	editMsg := tgbotapi.NewEditMessageTextAndMarkup(req.ChatID, req.MessageID, text, keyboard)
+	editMsg.ParseMode = tgbotapi.ModeMarkdownV2
+	_, err := h.Bot.Send(editMsg)
+	if isMessageNotModified(err) {
+		// Повторное нажатие той же кнопки — экран уже актуален
+		return nil
+	}
+	return err
+}
+
+// sendScreen отправляет экран новым сообщением.
+func (h *BotHandler) sendScreen(chatID int64, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdownV2
+	msg.ReplyMarkup = keyboard
+	_, err := h.Bot.Send(msg)
+	return err
+}
+
+func isMessageNotModified(err error) bool {
+	var tgErr *tgbotapi.Error
+	return errors.As(err, &tgErr) && strings.Contains(tgErr.Message, "message is not modified")
+}
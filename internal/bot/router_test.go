@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLegacyCallbackData проверяет, что кнопки из старых сообщений
+// попадают в обработчики текущего формата.
+func TestLegacyCallbackData(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+		// routed — результат должен находить обработчик
+		routed bool
+	}{
+		{"get_ios_guide", "guide:ios", true},
+		{"get_android_guide", "guide:android", true},
+		{"get_mac_guide", "guide:mac", true},
+		{"get_windows_guide", "guide:windows", true},
+		{"get_device2", "get_device:2", true},
+		{"accept_delete_device1", "accept_delete_device:1", true},
+		{"delete_device3", "delete_device:3", true},
+		{"revoke_device2", "revoke_device:2", true},
+		// текущий формат и действия без слота не меняются
+		{"get_device:2", "get_device:2", true},
+		{"delete_device:3", "delete_device:3", true},
+		{"new_device", "new_device", true},
+		{"get_config", "get_config", true},
+		{"get_device0", "get_device0", false},
+		{"get_devicex", "get_devicex", false},
+	}
+
+	routes := (&BotHandler{}).callbackRoutes()
+	for _, tt := range tests {
+		got := legacyCallbackData(tt.data)
+		if got != tt.want {
+			t.Errorf("legacyCallbackData(%q) = %q, want %q", tt.data, got, tt.want)
+			continue
+		}
+		parts := strings.Split(got, ":")
+		route, ok := routes[parts[0]]
+		if routed := ok && validCallbackArgs(route.args, parts[1:]); routed != tt.routed {
+			t.Errorf("%q -> %q: routed = %v, want %v", tt.data, got, routed, tt.routed)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"time"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+	"go-vpn-bot/internal/markdown"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Экраны — сообщения с кнопками, которые бот показывает и редактирует по
+// нажатию. Функции экранов не обращаются к базе и Telegram и возвращают
+// текст в разметке MarkdownV2: тексты каталога и подставленные значения
+// экранируются пакетом markdown, поэтому ссылка или название сервера с
+// любыми символами не ломают отправку.
+
+// welcomeScreen — приветствие нового пользователя.
+func welcomeScreen(lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	button := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "start.button"), actionStarted)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button),
+	)
+	return markdown.Escape(i18n.T(lang, "start.welcome")), keyboard
+}
+
+// mainMenu возвращает текст и кнопки главного меню пользователя.
+func mainMenu(user database.User) (string, tgbotapi.InlineKeyboardMarkup) {
+	lang := userLanguage(user)
+	trialEnd := user.SubscriptionEndDate.Time
+	daysRemaining := int(trialEnd.Sub(time.Now()).Hours() / 24)
+	var text string
+	if user.IsTrial {
+		text = i18n.T(lang, "menu.trial", i18n.N(lang, "days", daysRemaining+1))
+	}
+
+	if !user.IsTrial {
+		text = i18n.T(lang, "menu.paid", i18n.N(lang, "days", daysRemaining+1))
+	}
+
+	if !user.IsActive {
+		text = i18n.T(lang, "menu.inactive")
+	}
+
+	if user.IsFriend {
+		text = i18n.T(lang, "menu.friend")
+	}
+
+	// Создаем inline-кнопки для различных платформ
+	buttonPay := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.pay"), actionPay)
+	buttonConfigs := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "menu.configs"), actionConfigs)
+	buttonSupport := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "menu.support"), actionSupport)
+	buttonGuide := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "menu.guide"), actionGuides)
+	buttonSettings := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "menu.settings"), actionSettings)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonPay),
+		tgbotapi.NewInlineKeyboardRow(buttonConfigs),
+		tgbotapi.NewInlineKeyboardRow(buttonSupport),
+		tgbotapi.NewInlineKeyboardRow(buttonGuide),
+		tgbotapi.NewInlineKeyboardRow(buttonSettings),
+	)
+	return markdown.Escape(text), keyboard
+}
+
+// startedScreen показывает первый конфиг пользователя и выбор инструкции.
+func startedScreen(lang string, device database.Device) (string, tgbotapi.InlineKeyboardMarkup) {
+	text := markdown.Format(i18n.T(lang, "started.text"), device.Location, markdown.Pre(device.Link))
+
+	buttonMain := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)
+	rows := guidePlatformRows()
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttonMain))
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// configListScreen — список устройств пользователя. Кнопка добавления
+// показывается, пока не достигнут лимит устройств тарифа.
+func configListScreen(lang string, user database.User, devices []database.Device, limit int) (string, tgbotapi.InlineKeyboardMarkup) {
+	buttonMain := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)
+
+	if !user.IsActive {
+		buttonPay := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.pay"), actionPay)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(buttonPay),
+			tgbotapi.NewInlineKeyboardRow(buttonMain),
+		)
+		return markdown.Escape(i18n.T(lang, "configs.inactive")), keyboard
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, device := range devices {
+		button := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "configs.device", device.Slot), callbackData(actionDevice, device.Slot))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	if len(devices) < limit {
+		button := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "configs.add"), actionNewDevice)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttonMain))
+	return markdown.Escape(i18n.T(lang, "configs.title")), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// deviceScreen показывает конфиг устройства. device равен nil, если в слоте
+// нет устройства.
+func deviceScreen(lang string, deviceNumber int, device *database.Device) (string, tgbotapi.InlineKeyboardMarkup) {
+	buttonMain := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)
+
+	if device == nil {
+		buttonPay := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.pay"), actionPay)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(buttonPay),
+			tgbotapi.NewInlineKeyboardRow(buttonMain),
+		)
+		return markdown.Format(i18n.T(lang, "device.empty"), deviceNumber), keyboard
+	}
+
+	text := markdown.Format(i18n.T(lang, "device.config"), deviceNumber, device.Location, markdown.Pre(device.Link))
+	if device.Status == database.DeviceStatusDisabled {
+		text += "\n\n" + markdown.Escape(i18n.T(lang, "device.disabled"))
+	}
+
+	buttonQR := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "device.qr"), callbackData(actionDeviceQR, deviceNumber))
+	buttonRevoke := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "device.revoke"), callbackData(actionRevokeDevice, deviceNumber))
+	buttonDelete := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "device.delete", deviceNumber), callbackData(actionAskDeleteDevice, deviceNumber))
+	buttonBack := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.back"), actionConfigs)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonQR),
+		tgbotapi.NewInlineKeyboardRow(buttonRevoke),
+		tgbotapi.NewInlineKeyboardRow(buttonDelete),
+		tgbotapi.NewInlineKeyboardRow(buttonBack),
+		tgbotapi.NewInlineKeyboardRow(buttonMain),
+	)
+	return text, keyboard
+}
+
+// confirmDeleteDeviceScreen спрашивает подтверждение удаления конфига.
+func confirmDeleteDeviceScreen(lang string, deviceNumber int) (string, tgbotapi.InlineKeyboardMarkup) {
+	buttonAccept := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.yes"), callbackData(actionDeleteDevice, deviceNumber))
+	buttonCancel := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.cancel"), callbackData(actionDevice, deviceNumber))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonAccept, buttonCancel),
+	)
+	return markdown.Format(i18n.T(lang, "device.confirm_delete"), deviceNumber), keyboard
+}
+
+// deviceDeletedScreen сообщает результат удаления конфига.
+func deviceDeletedScreen(lang string, deviceNumber int, failed bool) (string, tgbotapi.InlineKeyboardMarkup) {
+	key := "device.deleted"
+	if failed {
+		key = "device.delete_failed"
+	}
+
+	buttonBack := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.back"), actionConfigs)
+	buttonMain := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonBack),
+		tgbotapi.NewInlineKeyboardRow(buttonMain),
+	)
+	return markdown.Format(i18n.T(lang, key), deviceNumber), keyboard
+}
+
+// guideMenuScreen — выбор платформы для инструкции.
+func guideMenuScreen(lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	rows := guidePlatformRows()
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)))
+	return markdown.Escape(i18n.T(lang, "guides.menu")), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// supportScreen — открытое обращение в поддержку. ticket равен nil, если
+// чат поддержки не настроен.
+func supportScreen(lang string, ticket *database.SupportTicket) (string, tgbotapi.InlineKeyboardMarkup) {
+	buttonMain := tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttonMain))
+
+	if ticket == nil {
+		return markdown.Escape(i18n.T(lang, "support.unavailable")), keyboard
+	}
+	return markdown.Format(i18n.T(lang, "support.opened"), ticket.ID), keyboard
+}
+
+// settingsScreen — настройки пользователя с выбором языка.
+func settingsScreen(lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, l := range i18n.Languages {
+		title := i18n.Name(l)
+		if l == lang {
+			title = "✅ " + title
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(title, callbackData(actionLanguage, l))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "button.main"), actionMain)))
+
+	return markdown.Format(i18n.T(lang, "settings.title"), i18n.Name(lang)), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
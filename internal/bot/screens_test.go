@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+	"go-vpn-bot/internal/markdown"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ограничения Telegram на текст сообщения и данные кнопки.
+const (
+	maxMessageLength      = 4096
+	maxCallbackDataLength = 64
+)
+
+// Значения со всеми спецсимволами MarkdownV2: ссылки конфигов содержат
+// «_», «-», «.», «#», «=», а названия серверов — скобки и эмодзи.
+const (
+	testLocation = "🇩🇪 Germany (DE-1) *beta* [test]_~!"
+	testLink     = "vless://0b1c-2d_3e@de-1.example.com:443?type=ws&path=%2F`x`\\&sni=a.b#DE-1 (test)!"
+	testSubURL   = "https://example.com/sub/abc_def-123?x=1#frag"
+)
+
+type testScreen struct {
+	name     string
+	text     string
+	keyboard tgbotapi.InlineKeyboardMarkup
+}
+
+func allScreens(lang string) []testScreen {
+	now := time.Now()
+	trial := database.User{ID: 1, IsActive: true, IsTrial: true, Language: lang,
+		SubscriptionEndDate: sql.NullTime{Time: now.Add(72 * time.Hour), Valid: true}}
+	paid := database.User{ID: 1, IsActive: true, Language: lang,
+		SubscriptionEndDate: sql.NullTime{Time: now.Add(30 * 24 * time.Hour), Valid: true}}
+	inactive := database.User{ID: 1, Language: lang}
+	friend := database.User{ID: 1, IsActive: true, IsFriend: true, Language: lang}
+
+	device := database.Device{ID: 7, UserID: 1, Slot: 2, Location: testLocation, Link: testLink, SubscriptionURL: testSubURL}
+	disabled := device
+	disabled.Status = database.DeviceStatusDisabled
+	devices := []database.Device{device, {ID: 8, UserID: 1, Slot: 3, Location: testLocation, Link: testLink}}
+
+	var screens []testScreen
+	add := func(name string, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+		screens = append(screens, testScreen{name, text, keyboard})
+	}
+
+	text, keyboard := welcomeScreen(lang)
+	add("welcome", text, keyboard)
+	for name, user := range map[string]database.User{"trial": trial, "paid": paid, "inactive": inactive, "friend": friend} {
+		text, keyboard = mainMenu(user)
+		add("main menu "+name, text, keyboard)
+	}
+	text, keyboard = startedScreen(lang, device)
+	add("started", text, keyboard)
+	text, keyboard = configListScreen(lang, paid, devices, 3)
+	add("configs", text, keyboard)
+	text, keyboard = configListScreen(lang, paid, devices, 2)
+	add("configs at limit", text, keyboard)
+	text, keyboard = configListScreen(lang, inactive, nil, 3)
+	add("configs inactive", text, keyboard)
+	text, keyboard = deviceScreen(lang, 2, &device)
+	add("device", text, keyboard)
+	text, keyboard = deviceScreen(lang, 2, &disabled)
+	add("device disabled", text, keyboard)
+	text, keyboard = deviceScreen(lang, 3, nil)
+	add("device empty", text, keyboard)
+	text, keyboard = confirmDeleteDeviceScreen(lang, 2)
+	add("confirm delete", text, keyboard)
+	text, keyboard = deviceDeletedScreen(lang, 2, false)
+	add("deleted", text, keyboard)
+	text, keyboard = deviceDeletedScreen(lang, 2, true)
+	add("delete failed", text, keyboard)
+	text, keyboard = guideMenuScreen(lang)
+	add("guide menu", text, keyboard)
+	text, keyboard = supportScreen(lang, nil)
+	add("support unavailable", text, keyboard)
+	text, keyboard = supportScreen(lang, &database.SupportTicket{ID: 42, UserID: 1})
+	add("support opened", text, keyboard)
+	text, keyboard = settingsScreen(lang)
+	add("settings", text, keyboard)
+	return screens
+}
+
+// TestScreensMarkdown проверяет, что каждый экран на каждом языке —
+// корректный MarkdownV2 даже со спецсимволами в ссылках и названиях.
+func TestScreensMarkdown(t *testing.T) {
+	routes := (&BotHandler{}).callbackRoutes()
+	for _, lang := range i18n.Languages {
+		for _, s := range allScreens(lang) {
+			if err := markdown.Validate(s.text); err != nil {
+				t.Errorf("%s/%s: %v\n%s", lang, s.name, err, s.text)
+			}
+			if n := utf8.RuneCountInString(s.text); n > maxMessageLength {
+				t.Errorf("%s/%s: text has %d characters, limit %d", lang, s.name, n, maxMessageLength)
+			}
+			if len(s.keyboard.InlineKeyboard) == 0 {
+				t.Errorf("%s/%s: no buttons", lang, s.name)
+			}
+			for _, row := range s.keyboard.InlineKeyboard {
+				for _, button := range row {
+					if button.CallbackData == nil || *button.CallbackData == "" {
+						t.Errorf("%s/%s: button %q has no callback data", lang, s.name, button.Text)
+						continue
+					}
+					if len(*button.CallbackData) > maxCallbackDataLength {
+						t.Errorf("%s/%s: callback data %q is longer than %d bytes", lang, s.name, *button.CallbackData, maxCallbackDataLength)
+					}
+					parts := strings.Split(*button.CallbackData, ":")
+					if route, ok := routes[parts[0]]; !ok || !validCallbackArgs(route.args, parts[1:]) {
+						t.Errorf("%s/%s: button %q has no route for %q", lang, s.name, button.Text, *button.CallbackData)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestScreensKeepLink проверяет, что ссылка конфига попадает в блок кода
+// без изменений, кроме обязательного экранирования ` и \.
+func TestScreensKeepLink(t *testing.T) {
+	want := markdown.Pre(testLink).String()
+	for _, lang := range i18n.Languages {
+		for _, s := range allScreens(lang) {
+			switch s.name {
+			case "started", "device", "device disabled":
+			default:
+				continue
+			}
+			if !strings.Contains(s.text, want) {
+				t.Errorf("%s/%s: link block %q not found in\n%s", lang, s.name, want, s.text)
+			}
+		}
+	}
+}
+
+// TestGuideTemplates заполняет шаблоны всех инструкций из репозитория.
+func TestGuideTemplates(t *testing.T) {
+	dir := filepath.Join("..", "..", defaultGuidesDir)
+	data := guideData{Location: testLocation, Link: testLink, SubscriptionURL: testSubURL}
+	for _, lang := range i18n.Languages {
+		for _, p := range guidePlatforms {
+			steps, err := loadGuide(guideDir(dir, lang, p.key), lang, p, data)
+			if err != nil {
+				t.Errorf("%s/%s: %v", lang, p.key, err)
+				continue
+			}
+			for i, step := range steps {
+				if n := utf8.RuneCountInString(step.text); n > maxMessageLength {
+					t.Errorf("%s/%s step %d: text has %d characters, limit %d", lang, p.key, i+1, n, maxMessageLength)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// userLanguage возвращает язык интерфейса пользователя. Пользователи,
+// зарегистрированные до появления выбора языка, видят русский интерфейс.
+func userLanguage(user database.User) string {
+	if i18n.Supported(user.Language) {
+		return user.Language
+	}
+	return i18n.Default
+}
+
+// chatLanguage возвращает язык пользователя по ID чата, а для
+// незарегистрированных — язык из профиля Telegram.
+func (h *BotHandler) chatLanguage(ctx context.Context, chatID int64, from *tgbotapi.User) string {
+	user, err := h.DB.GetUserByID(ctx, chatID)
+	if err == nil {
+		return userLanguage(user)
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		logWithLocation("Ошибка получения пользователя %d: %v", chatID, err)
+	}
+	if from != nil {
+		return i18n.Match(from.LanguageCode)
+	}
+	return i18n.Default
+}
+
+// replyUnknownCommand отвечает на команду, которой нет или которая
+// недоступна пользователю.
+func (h *BotHandler) replyUnknownCommand(ctx context.Context, message *tgbotapi.Message) {
+	h.sendText(message.Chat.ID, i18n.T(h.chatLanguage(ctx, message.Chat.ID, message.From), "command.unknown"))
+}
+
+// handleSettings показывает настройки пользователя.
+func (h *BotHandler) handleSettings(ctx context.Context, req *callbackRequest) error {
+	text, keyboard := settingsScreen(req.Lang)
+	return h.editScreen(req, text, keyboard)
+}
+
+// handleSetLanguage меняет язык интерфейса: "set_lang:<язык>".
+func (h *BotHandler) handleSetLanguage(ctx context.Context, req *callbackRequest) error {
+	lang := req.String(0)
+	if !i18n.Supported(lang) {
+		req.Answer(req.T(answerStale))
+		return nil
+	}
+
+	if err := h.DB.SetUserLanguage(ctx, req.ChatID, lang); err != nil {
+		return fmt.Errorf("ошибка сохранения языка: %w", err)
+	}
+	req.Lang = lang
+	req.User.Language = lang
+
+	req.Answer(req.T("settings.changed"))
+	return h.handleSettings(ctx, req)
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Состояния диалога. Пока у пользователя есть состояние, его сообщения
+// (кроме команд) получает обработчик состояния, а не «Неизвестная команда».
+const (
+	stateSupport          = "support"
+	stateBroadcast        = "broadcast"
+	stateBroadcastConfirm = "broadcast_confirm"
+)
+
+// stateRoute описывает шаг диалога: сколько бот ждёт ввода и кто его
+// обрабатывает. Обработчик сам переводит диалог в следующее состояние
+// или завершает его.
+type stateRoute struct {
+	timeout time.Duration
+	handle  func(ctx context.Context, message *tgbotapi.Message, state database.UserState) error
+}
+
+func (h *BotHandler) stateRoutes() map[string]stateRoute {
+	return map[string]stateRoute{
+		stateSupport:          {timeout: 24 * time.Hour, handle: h.handleSupportInput},
+		stateBroadcast:        {timeout: 15 * time.Minute, handle: h.handleBroadcastInput},
+		stateBroadcastConfirm: {timeout: 15 * time.Minute, handle: h.handleBroadcastInput},
+	}
+}
+
+// setState переводит диалог с пользователем в состояние name. Таймаут
+// отсчитывается заново при каждом вызове.
+func (h *BotHandler) setState(ctx context.Context, userID int64, name string, data map[string]string) error {
+	route, ok := h.stateRoutes()[name]
+	if !ok {
+		return fmt.Errorf("неизвестное состояние %s", name)
+	}
+	return h.DB.SetUserState(ctx, database.UserState{
+		UserID:    userID,
+		State:     name,
+		Data:      data,
+		ExpiresAt: time.Now().Add(route.timeout),
+	})
+}
+
+// currentState возвращает действующее состояние пользователя; истёкшее
+// удаляется и считается отсутствующим.
+func (h *BotHandler) currentState(ctx context.Context, userID int64) (database.UserState, bool) {
+	state, err := h.DB.GetUserState(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return database.UserState{}, false
+	}
+	if err != nil {
+		logWithLocation("Ошибка получения состояния пользователя %d: %v", userID, err)
+		return database.UserState{}, false
+	}
+	if state.Expired(time.Now()) {
+		h.clearState(ctx, userID)
+		return database.UserState{}, false
+	}
+	return state, true
+}
+
+func (h *BotHandler) clearState(ctx context.Context, userID int64) {
+	if err := h.DB.ClearUserState(ctx, userID); err != nil {
+		logWithLocation("Ошибка сброса состояния пользователя %d: %v", userID, err)
+	}
+}
+
+// handleStateMessage передаёт сообщение обработчику состояния диалога.
+// Возвращает false, если бот не ждёт от пользователя ввода.
+func (h *BotHandler) handleStateMessage(ctx context.Context, message *tgbotapi.Message) bool {
+	state, err := h.DB.GetUserState(ctx, message.Chat.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		logWithLocation("Ошибка получения состояния пользователя %d: %v", message.Chat.ID, err)
+		return false
+	}
+
+	route, ok := h.stateRoutes()[state.State]
+	if !ok {
+		logWithLocation("Неизвестное состояние %s пользователя %d", state.State, message.Chat.ID)
+		h.clearState(ctx, message.Chat.ID)
+		return false
+	}
+	if state.Expired(time.Now()) {
+		h.clearState(ctx, message.Chat.ID)
+		h.sendText(message.Chat.ID, i18n.T(h.chatLanguage(ctx, message.Chat.ID, message.From), "state.expired"))
+		return true
+	}
+
+	if err := route.handle(ctx, message, state); err != nil {
+		logWithLocation("Ошибка обработки ввода в состоянии %s пользователя %d: %v", state.State, message.Chat.ID, err)
+		h.sendText(message.Chat.ID, i18n.T(h.chatLanguage(ctx, message.Chat.ID, message.From), answerErrorText))
+	}
+	return true
+}
+
+// handleCancelCommand прерывает текущий диалог: /cancel.
+func (h *BotHandler) handleCancelCommand(ctx context.Context, message *tgbotapi.Message) {
+	lang := h.chatLanguage(ctx, message.Chat.ID, message.From)
+	if _, ok := h.currentState(ctx, message.Chat.ID); !ok {
+		h.sendText(message.Chat.ID, i18n.T(lang, "state.none"))
+		return
+	}
+	h.clearState(ctx, message.Chat.ID)
+	h.sendText(message.Chat.ID, i18n.T(lang, "state.cancelled"))
+}
+
+// handleCancelInput — кнопка «❌ Отмена» в диалоге.
+func (h *BotHandler) handleCancelInput(ctx context.Context, req *callbackRequest) error {
+	h.clearState(ctx, req.ChatID)
+	req.Answer(req.T("state.answer"))
+	editMsg := tgbotapi.NewEditMessageText(req.ChatID, req.MessageID, req.T("state.cancelled"))
+	_, err := h.Bot.Send(editMsg)
+	return err
+}
@@ -0,0 +1,292 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-vpn-bot/internal/database"
+	"go-vpn-bot/internal/i18n"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Обращения в поддержку. Пользователь нажимает «🆘 Написать в поддержку»,
+// диалог переходит в состояние stateSupport, и его сообщения копируются в
+// чат поддержки (support.chat_id,
+// при необходимости в тему support.thread_id). Агент отвечает реплаем на
+// сообщение бота в этом чате, и ответ копируется пользователю. Командой
+// /close (реплаем или /close <номер>) агент закрывает обращение.
+//
+// Бот должен быть участником чата поддержки; в режиме приватности он
+// получает только команды и ответы на свои сообщения, чего достаточно.
+
+// handleSupport открывает обращение пользователя и объясняет, что делать дальше.
+func (h *BotHandler) handleSupport(ctx context.Context, req *callbackRequest) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+
+	if cfg.Support.ChatID == 0 {
+		text, keyboard := supportScreen(req.Lang, nil)
+		return h.editScreen(req, text, keyboard)
+	}
+
+	ticket, created, err := h.DB.OpenTicket(ctx, req.ChatID)
+	if err != nil {
+		req.Answer(req.T("support.open_failed"))
+		return fmt.Errorf("ошибка открытия обращения: %w", err)
+	}
+
+	if created {
+		h.sendTicketCard(ctx, cfg, ticket)
+	}
+	if err := h.setState(ctx, req.ChatID, stateSupport, nil); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния: %w", err)
+	}
+
+	text, keyboard := supportScreen(req.Lang, &ticket)
+	return h.editScreen(req, text, keyboard)
+}
+
+// sendTicketCard отправляет в чат поддержки карточку нового обращения:
+// пользователь, тариф и устройства. Ответ на карточку тоже уходит пользователю.
+func (h *BotHandler) sendTicketCard(ctx context.Context, cfg *config.Config, ticket database.SupportTicket) {
+	user, err := h.DB.GetUserByID(ctx, ticket.UserID)
+	if err != nil {
+		logWithLocation("Ошибка получения пользователя %d: %v", ticket.UserID, err)
+		return
+	}
+	devices, err := h.DB.GetUserDevices(ctx, ticket.UserID)
+	if err != nil {
+		logWithLocation("Ошибка получения устройств пользователя %d: %v", ticket.UserID, err)
+	}
+
+	params := tgbotapi.Params{"text": formatTicketCard(ticket, user, devices)}
+	messageID, err := h.sendToSupportChat(cfg, "sendMessage", params)
+	if err != nil {
+		logWithLocation("Ошибка отправки карточки обращения %d: %v", ticket.ID, err)
+		return
+	}
+	if err := h.DB.AddSupportMessage(ctx, ticket.ID, cfg.Support.ChatID, messageID, false); err != nil {
+		logWithLocation("Ошибка сохранения сообщения обращения %d: %v", ticket.ID, err)
+	}
+}
+
+func formatTicketCard(ticket database.SupportTicket, user database.User, devices []database.Device) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🆘 Обращение №%d\n\n", ticket.ID)
+	fmt.Fprintf(&b, "Пользователь: %d", user.ID)
+	if user.Username != "" {
+		fmt.Fprintf(&b, " (@%s)", user.Username)
+	}
+	b.WriteString("\n")
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		fmt.Fprintf(&b, "Имя: %s\n", name)
+	}
+
+	plan := "платная подписка"
+	switch {
+	case user.IsFriend:
+		plan = "друг"
+	case !user.IsActive:
+		plan = "подписка не активна"
+	case user.IsTrial:
+		plan = "пробный период"
+	}
+	fmt.Fprintf(&b, "Тариф: %s, до %s\n", plan, formatNullTime(user.SubscriptionEndDate))
+
+	fmt.Fprintf(&b, "Устройства: %d\n", len(devices))
+	for _, d := range devices {
+		fmt.Fprintf(&b, "  %d. %s, %s, %s\n", d.Slot, d.Location, d.Protocol, d.Status)
+	}
+
+	b.WriteString("\nОтветьте на это или следующие сообщения, чтобы написать пользователю. /close — закрыть обращение.")
+	return b.String()
+}
+
+// handleSupportInput пересылает сообщение пользователя в состоянии
+// stateSupport в чат поддержки и продлевает ожидание ввода.
+func (h *BotHandler) handleSupportInput(ctx context.Context, message *tgbotapi.Message, state database.UserState) error {
+	lang := h.chatLanguage(ctx, message.Chat.ID, message.From)
+	ticket, err := h.DB.GetOpenTicket(ctx, message.Chat.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		// Обращение закрыли, пока пользователь писал
+		h.clearState(ctx, message.Chat.ID)
+		h.sendText(message.Chat.ID, i18n.T(lang, "support.already_closed"))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка получения обращения: %w", err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+	if cfg.Support.ChatID == 0 {
+		h.clearState(ctx, message.Chat.ID)
+		h.sendText(message.Chat.ID, i18n.T(lang, "support.unavailable"))
+		return nil
+	}
+
+	params := tgbotapi.Params{}
+	params.AddNonZero64("from_chat_id", message.Chat.ID)
+	params.AddNonZero("message_id", message.MessageID)
+	messageID, err := h.sendToSupportChat(cfg, "copyMessage", params)
+	if err != nil {
+		logWithLocation("Ошибка пересылки сообщения обращения %d: %v", ticket.ID, err)
+		h.sendText(message.Chat.ID, i18n.T(lang, "support.forward_failed"))
+		return nil
+	}
+	if err := h.DB.AddSupportMessage(ctx, ticket.ID, cfg.Support.ChatID, messageID, false); err != nil {
+		logWithLocation("Ошибка сохранения сообщения обращения %d: %v", ticket.ID, err)
+	}
+	if err := h.setState(ctx, message.Chat.ID, stateSupport, nil); err != nil {
+		logWithLocation("Ошибка продления состояния пользователя %d: %v", message.Chat.ID, err)
+	}
+
+	h.sendText(message.Chat.ID, i18n.T(lang, "support.forwarded", ticket.ID))
+	return nil
+}
+
+// handleSupportChatMessage обрабатывает сообщения в чате поддержки:
+// ответы агентов и команду /close.
+func (h *BotHandler) handleSupportChatMessage(ctx context.Context, message *tgbotapi.Message) {
+	if message.Command() == "close" {
+		h.handleCloseTicket(ctx, message)
+		return
+	}
+	if message.ReplyToMessage == nil || message.From == nil || message.From.IsBot {
+		return
+	}
+
+	ticket, err := h.DB.GetTicketBySupportMessage(ctx, message.Chat.ID, message.ReplyToMessage.MessageID)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка поиска обращения по сообщению %d: %v", message.ReplyToMessage.MessageID, err)
+		return
+	}
+	if ticket.Status == database.TicketStatusClosed {
+		h.replyInSupportChat(message, fmt.Sprintf("Обращение №%d закрыто, сообщение не отправлено.", ticket.ID))
+		return
+	}
+
+	copyMsg := tgbotapi.NewCopyMessage(ticket.UserID, message.Chat.ID, message.MessageID)
+	if _, err := h.Bot.Send(copyMsg); err != nil {
+		if isBlockedByUser(err) {
+			if err := h.DB.SetBlockedBot(ctx, ticket.UserID, true); err != nil {
+				logWithLocation("Ошибка обновления статуса блокировки пользователя %d: %v", ticket.UserID, err)
+			}
+			h.replyInSupportChat(message, "Пользователь заблокировал бота, ответ не доставлен.")
+			return
+		}
+		logWithLocation("Ошибка отправки ответа по обращению %d: %v", ticket.ID, err)
+		h.replyInSupportChat(message, "Не удалось отправить ответ пользователю.")
+		return
+	}
+
+	// Реплай на ответ агента тоже относится к обращению
+	if err := h.DB.AddSupportMessage(ctx, ticket.ID, message.Chat.ID, message.MessageID, true); err != nil {
+		logWithLocation("Ошибка сохранения сообщения обращения %d: %v", ticket.ID, err)
+	}
+	// Ответ пользователя на сообщение агента снова уйдёт в поддержку, но
+	// начатый пользователем другой диалог ответ агента не прерывает
+	if state, ok := h.currentState(ctx, ticket.UserID); ok && state.State != stateSupport {
+		return
+	}
+	if err := h.setState(ctx, ticket.UserID, stateSupport, nil); err != nil {
+		logWithLocation("Ошибка сохранения состояния пользователя %d: %v", ticket.UserID, err)
+	}
+}
+
+// handleCloseTicket закрывает обращение: /close <номер> или /close реплаем
+// на сообщение обращения.
+func (h *BotHandler) handleCloseTicket(ctx context.Context, message *tgbotapi.Message) {
+	if message.From == nil {
+		return
+	}
+
+	var ticket database.SupportTicket
+	var err error
+	if arg := strings.TrimPrefix(strings.TrimSpace(message.CommandArguments()), "№"); arg != "" {
+		id, parseErr := strconv.ParseInt(arg, 10, 64)
+		if parseErr != nil {
+			h.replyInSupportChat(message, "Использование: /close <номер> или /close в ответ на сообщение обращения")
+			return
+		}
+		ticket, err = h.DB.GetTicket(ctx, id)
+	} else if message.ReplyToMessage != nil {
+		ticket, err = h.DB.GetTicketBySupportMessage(ctx, message.Chat.ID, message.ReplyToMessage.MessageID)
+	} else {
+		h.replyInSupportChat(message, "Использование: /close <номер> или /close в ответ на сообщение обращения")
+		return
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		h.replyInSupportChat(message, "Обращение не найдено")
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка поиска обращения: %v", err)
+		return
+	}
+
+	err = h.DB.CloseTicket(ctx, ticket.ID, message.From.ID)
+	if errors.Is(err, database.ErrTicketClosed) {
+		h.replyInSupportChat(message, fmt.Sprintf("Обращение №%d уже закрыто", ticket.ID))
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка закрытия обращения %d: %v", ticket.ID, err)
+		h.replyInSupportChat(message, "Не удалось закрыть обращение, попробуйте позже")
+		return
+	}
+
+	h.replyInSupportChat(message, fmt.Sprintf("✅ Обращение №%d закрыто", ticket.ID))
+	if state, ok := h.currentState(ctx, ticket.UserID); ok && state.State == stateSupport {
+		h.clearState(ctx, ticket.UserID)
+	}
+
+	user, err := h.DB.GetUserByID(ctx, ticket.UserID)
+	if err != nil {
+		logWithLocation("Ошибка получения пользователя %d: %v", ticket.UserID, err)
+		return
+	}
+	h.notifyUser(ctx, user, i18n.T(userLanguage(user), "support.closed", ticket.ID))
+}
+
+// sendToSupportChat вызывает метод Bot API с чатом (и темой) поддержки и
+// возвращает ID отправленного сообщения. Используется MakeRequest, так как
+// библиотека не поддерживает message_thread_id.
+func (h *BotHandler) sendToSupportChat(cfg *config.Config, method string, params tgbotapi.Params) (int, error) {
+	params.AddNonZero64("chat_id", cfg.Support.ChatID)
+	params.AddNonZero("message_thread_id", cfg.Support.ThreadID)
+
+	resp, err := h.Bot.MakeRequest(method, params)
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		MessageID int `json:"message_id"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return 0, err
+	}
+	return result.MessageID, nil
+}
+
+func (h *BotHandler) replyInSupportChat(message *tgbotapi.Message, text string) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyToMessageID = message.MessageID
+	if _, err := h.Bot.Send(msg); err != nil {
+		logWithLocation("Ошибка отправки сообщения в чат поддержки: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-vpn-bot/internal/database"
+
+	config "go-vpn-bot/configs"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleUserCommand показывает администратору карточку пользователя:
+// /user @username или /user <id>.
+func (h *BotHandler) handleUserCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	query := strings.TrimSpace(message.CommandArguments())
+	if query == "" {
+		h.sendText(message.Chat.ID, "Использование: /user @username или /user <id>")
+		return
+	}
+
+	var user database.User
+	if id, parseErr := strconv.ParseInt(query, 10, 64); parseErr == nil {
+		user, err = h.DB.GetUserByID(ctx, id)
+	} else {
+		user, err = h.DB.GetUserByUsername(ctx, query)
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		h.sendText(message.Chat.ID, fmt.Sprintf("Пользователь %s не найден", query))
+		return
+	}
+	if err != nil {
+		logWithLocation("Ошибка поиска пользователя %s: %v", query, err)
+		h.sendText(message.Chat.ID, "Не удалось найти пользователя, попробуйте позже")
+		return
+	}
+
+	subs, err := h.DB.GetUserSubscriptions(ctx, user.ID)
+	if err != nil {
+		logWithLocation("Ошибка получения периодов подписки пользователя %d: %v", user.ID, err)
+	}
+
+	h.sendText(message.Chat.ID, formatUser(user, subs))
+}
+
+// handleResetTrafficCommand обнуляет администратору использованный трафик
+// всех устройств пользователя: /reset_traffic <id>.
+func (h *BotHandler) handleResetTrafficCommand(ctx context.Context, message *tgbotapi.Message) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logWithLocation("Ошибка загрузки конфигурации: %v", err)
+		return
+	}
+
+	if message.From == nil || message.From.ID != cfg.Bot.AdminID {
+		h.replyUnknownCommand(ctx, message)
+		return
+	}
+
+	userID, err := strconv.ParseInt(strings.TrimSpace(message.CommandArguments()), 10, 64)
+	if err != nil {
+		h.sendText(message.Chat.ID, "Использование: /reset_traffic <id>")
+		return
+	}
+
+	devices, err := h.DB.GetUserDevices(ctx, userID)
+	if err != nil {
+		logWithLocation("Ошибка получения устройств пользователя %d: %v", userID, err)
+		h.sendText(message.Chat.ID, "Не удалось получить устройства, попробуйте позже")
+		return
+	}
+	if len(devices) == 0 {
+		h.sendText(message.Chat.ID, fmt.Sprintf("У пользователя %d нет устройств", userID))
+		return
+	}
+
+	var reset []string
+	for _, device := range devices {
+		if err := resetTrafficMarzban(device.MarzbanUsername); err != nil {
+			logWithLocation("Ошибка сброса трафика пользователя %d: %v", userID, err)
+			continue
+		}
+		reset = append(reset, device.MarzbanUsername)
+	}
+
+	h.recordEvent(ctx, database.EventAdminAction, database.ActorAdmin(message.From.ID), userID, map[string]interface{}{
+		"command": "reset_traffic",
+		"devices": reset,
+	})
+
+	if len(reset) < len(devices) {
+		h.sendText(message.Chat.ID, fmt.Sprintf("Трафик сброшен для %d из %d устройств пользователя %d, повторите команду позже", len(reset), len(devices), userID))
+		return
+	}
+	h.sendText(message.Chat.ID, fmt.Sprintf("Трафик сброшен для %d устройств пользователя %d", len(reset), userID))
+}
+
+func formatUser(user database.User, subs []database.Subscription) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "👤 Пользователь %d\n", user.ID)
+	if user.Username != "" {
+		fmt.Fprintf(&b, "Username: @%s\n", user.Username)
+	}
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		fmt.Fprintf(&b, "Имя: %s\n", name)
+	}
+	if user.LanguageCode != "" {
+		fmt.Fprintf(&b, "Язык: %s\n", user.LanguageCode)
+	}
+	fmt.Fprintf(&b, "Зарегистрирован: %s\n", formatNullTime(user.CreatedAt))
+	fmt.Fprintf(&b, "Последняя активность: %s\n", formatNullTime(user.LastSeenAt))
+	fmt.Fprintf(&b, "Подписка до: %s\n", formatNullTime(user.SubscriptionEndDate))
+	if len(subs) > 0 {
+		fmt.Fprintf(&b, "Периодов подписки: %d, оплачено дней: %.0f\n", len(subs), database.TotalDays(subs, database.SourcePayment))
+	}
+	fmt.Fprintf(&b, "Активен: %t, пробный период: %t, друг: %t\n", user.IsActive, user.IsTrial, user.IsFriend)
+	fmt.Fprintf(&b, "Баланс: %.2f\n", user.Balance)
+	if user.BlockedBot {
+		b.WriteString("⛔️ Бот заблокирован пользователем\n")
+	}
+	return b.String()
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "неизвестно"
+	}
+	return t.Time.Format("02.01.2006 15:04")
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrBackupUnsupported возвращается Backup для СУБД, резервные копии
+// которых делаются её собственными средствами (pg_dump для PostgreSQL).
+var ErrBackupUnsupported = errors.New("резервное копирование поддерживается только для SQLite")
+
+const (
+	backupPrefix     = "vpn-bot-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405"
+)
+
+// Backuper создаёт резервные копии базы; реализуется DB.
+type Backuper interface {
+	Backup(ctx context.Context, dir string, keep int) (string, error)
+}
+
+var _ Backuper = (*DB)(nil)
+
+// Backup сохраняет копию работающей базы SQLite в dir через VACUUM INTO,
+// проверяет целостность полученного файла и оставляет в каталоге только
+// keep последних копий (keep <= 0 — без удаления). Возвращает путь к копии.
+func (db *DB) Backup(ctx context.Context, dir string, keep int) (string, error) {
+	if db.dialect.name != sqliteDialect.name {
+		return "", ErrBackupUnsupported
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("ошибка создания каталога копий: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeLayout)+backupSuffix)
+	// VACUUM INTO не перезаписывает существующий файл
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("копия %s уже существует", path)
+	}
+
+	if _, err := db.Conn.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("ошибка создания копии: %w", err)
+	}
+
+	if err := checkIntegrity(ctx, path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	if err := pruneBackups(dir, keep); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// checkIntegrity открывает копию и выполняет PRAGMA integrity_check.
+func checkIntegrity(ctx context.Context, path string) error {
+	conn, err := sql.Open(sqliteDialect.driver, path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("ошибка проверки копии %s: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("копия %s повреждена: %s", path, result)
+	}
+	return nil
+}
+
+// ListBackups возвращает пути к копиям в dir, от старых к новым.
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// Время в имени файла сортируется лексикографически
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups удаляет старые копии, оставляя keep последних.
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("ошибка удаления старой копии: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
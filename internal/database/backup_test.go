@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	db := openTestDB(t, backends()[0])
+	ctx := context.Background()
+
+	if err := db.CreateUser(ctx, 1, 7); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	// Старые копии, которые должны быть удалены ротацией
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"vpn-bot-20200101-000000.db", "vpn-bot-20200102-000000.db", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := db.Backup(ctx, dir, 2)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != filepath.Join(dir, "vpn-bot-20200102-000000.db") || backups[1] != path {
+		t.Errorf("backups after rotation = %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("rotation removed an unrelated file: %v", err)
+	}
+
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil || count != 1 {
+		t.Errorf("users in backup = %d, %v; want 1", count, err)
+	}
+}
+
+func TestCheckIntegrityRejectsGarbage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.db")
+	if err := os.WriteFile(path, []byte("definitely not a database"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkIntegrity(context.Background(), path); err == nil {
+		t.Error("checkIntegrity accepted a corrupt file")
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSQLiteConnectionSettings(t *testing.T) {
+	db := openTestDB(t, backends()[0])
+	ctx := context.Background()
+
+	var journalMode string
+	if err := db.queryRow(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("journal_mode = %q, %v; want wal", journalMode, err)
+	}
+	var busyTimeout int
+	if err := db.queryRow(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout == 0 {
+		t.Errorf("busy_timeout = %d, %v; want > 0", busyTimeout, err)
+	}
+	var foreignKeys bool
+	if err := db.queryRow(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil || !foreignKeys {
+		t.Errorf("foreign_keys = %t, %v; want true", foreignKeys, err)
+	}
+
+	if err := db.CreateDevice(ctx, &Device{UserID: 404, Slot: 1, MarzbanUsername: "404_device1"}); err == nil {
+		t.Error("CreateDevice accepted a device of a missing user")
+	}
+}
+
+// TestConcurrentWriters имитирует обработчик бота, проверку подписок и
+// вебхук платежей, пишущих в базу одновременно.
+func TestConcurrentWriters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db := openTestDB(t, b)
+		ctx := context.Background()
+
+		const (
+			writers = 8
+			rounds  = 25
+		)
+		for id := int64(1); id <= writers; id++ {
+			if err := db.CreateUser(ctx, id, 7); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, writers*rounds*3)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				userID := int64(w%writers + 1)
+				for i := 0; i < rounds; i++ {
+					// Каждый платёж пополняет баланс всех пользователей по кругу
+					p := &Payment{UserID: int64((w+i)%writers + 1), Amount: 1, ExternalID: fmt.Sprintf("w%d-%d", w, i)}
+					if err := db.CreatePayment(ctx, p); err != nil {
+						errs <- fmt.Errorf("CreatePayment: %w", err)
+					}
+					if err := db.TouchUser(ctx, Profile{UserID: userID, Username: fmt.Sprintf("user%d", w)}); err != nil {
+						errs <- fmt.Errorf("TouchUser: %w", err)
+					}
+					if _, err := db.GetUserDevices(ctx, userID); err != nil {
+						errs <- fmt.Errorf("GetUserDevices: %w", err)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Error(err)
+		}
+
+		var total float64
+		if err := db.queryRow(ctx, "SELECT SUM(balance) FROM users").Scan(&total); err != nil {
+			t.Fatalf("sum balance: %v", err)
+		}
+		if total != writers*rounds {
+			t.Errorf("total balance = %v, want %d", total, writers*rounds)
+		}
+	})
+}
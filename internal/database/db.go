@@ -1,16 +1,25 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	_ "github.com/lib/pq"  // PostgreSQL driver
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
 type DB struct {
 	Conn *sql.DB
+
+	dialect dialect
 }
 
 type User struct {
@@ -20,54 +29,137 @@ type User struct {
 	IsActive            bool
 	IsFriend            bool
 	SubscriptionEndDate sql.NullTime
-	Config1             string
-	Config2             string
-	Config3             string
 	ReffererId          int64
+
+	// Данные профиля Telegram, обновляются при каждом обращении к боту
+	Username     string
+	FirstName    string
+	LastName     string
+	LanguageCode string
+	CreatedAt    sql.NullTime
+	LastSeenAt   sql.NullTime
+	// BlockedBot — пользователь заблокировал бота, сообщения ему не доставляются
+	BlockedBot bool
+	// DeletedAt — время удаления данных по просьбе пользователя
+	DeletedAt sql.NullTime
+	// Language — выбранный язык интерфейса, пустой у пользователей,
+	// зарегистрированных до появления выбора языка
+	Language string
+}
+
+// Profile — данные пользователя из Telegram.
+type Profile struct {
+	UserID       int64
+	Username     string
+	FirstName    string
+	LastName     string
+	LanguageCode string
+}
+
+// defaultSQLitePath — файл базы SQLite, если DSN не задан в конфигурации.
+// База лежит в отдельном каталоге, который монтируется в контейнер целиком
+// вместе с файлами -wal и -shm (см. docker-compose.yml)
+const defaultSQLitePath = "/app/data/vpn-bot.db"
+
+// legacySQLitePath — прежний путь базы по умолчанию, куда старый
+// docker-compose.yml монтировал файл базы
+const legacySQLitePath = "/app/vpn-bot.db"
+
+// ConnectDB подключается к базе данных, выбранной в конфигурации
+// (database.driver: sqlite или postgres), и применяет миграции схемы
+func ConnectDB(driver, dsn string) (*DB, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if dsn == "" {
+		if d.name != sqliteDialect.name {
+			return nil, fmt.Errorf("не задан DSN для драйвера %s", driver)
+		}
+		if dsn, err = defaultSQLiteDSN(defaultSQLitePath, legacySQLitePath); err != nil {
+			return nil, err
+		}
+	}
+
+	return open(d, dsn)
+}
+
+// defaultSQLiteDSN выбирает файл базы, если DSN не задан. Пока развёртывание
+// монтирует базу по старому пути, открывается она: иначе бот создал бы
+// новую пустую базу, и все пользователи потеряли бы подписки. Если файлы
+// есть по обоим путям, непонятно, какой из них настоящий, и бот не запускается.
+func defaultSQLiteDSN(path, legacy string) (string, error) {
+	if _, err := os.Stat(legacy); errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	} else if err != nil {
+		return "", fmt.Errorf("ошибка проверки базы %s: %w", legacy, err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("найдены две базы SQLite: %s и %s; удалите лишнюю или укажите нужную в database.dsn", legacy, path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("ошибка проверки базы %s: %w", path, err)
+	}
+
+	log.Printf("База SQLite открыта по старому пути %s. Перенесите её в каталог %s (см. docker-compose.yml)", legacy, filepath.Dir(path))
+	return legacy, nil
 }
 
-// ConnectDB подключается к базе данных и создает таблицу, если она не существует
-func ConnectDB() (*DB, error) {
-	conn, err := sql.Open("sqlite", "/app/vpn-bot.db")
+func open(d dialect, dsn string) (*DB, error) {
+	conn, err := sql.Open(d.driver, d.withParams(dsn))
 	if err != nil {
 		return nil, err
 	}
+	conn.SetMaxOpenConns(d.maxOpenConns)
+	conn.SetMaxIdleConns(d.maxOpenConns)
 
 	if err = conn.Ping(); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	// Создаем таблицу, если она не существует
-	err = createUsersTable(conn)
+	migrations, err := dialectMigrations(d)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	return &DB{Conn: conn}, nil
-}
-
-// createUsersTable создает таблицу пользователей, если она не существует
-func createUsersTable(conn *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY,
-		balance REAL DEFAULT 0,
-		is_trial BOOLEAN DEFAULT FALSE,
-		is_active BOOLEAN DEFAULT FALSE,
-		is_friend BOOLEAN DEFAULT FALSE,
-		subscription_end_date DATETIME DEFAULT NULL,
-		config1 TEXT DEFAULT '',
-		config2 TEXT DEFAULT '',
-		config3 TEXT DEFAULT '',
-		refferer_id INTEGER DEFAULT NULL
-	);
-	`
-	_, err := conn.Exec(query)
+	// Приводим схему к актуальной версии
+	if err = migrate(conn, d, migrations); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{Conn: conn, dialect: d}, nil
+}
+
+func (db *DB) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.Conn.ExecContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *DB) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.Conn.QueryContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *DB) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.Conn.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// execAffecting выполняет UPDATE/DELETE и возвращает ErrNotFound,
+// если запрос не затронул ни одной строки.
+func (db *DB) execAffecting(ctx context.Context, query string, args ...interface{}) error {
+	res, err := db.exec(ctx, query, args...)
 	if err != nil {
-		log.Printf("Ошибка при создании таблицы: %v", err)
 		return err
 	}
-	log.Println("Таблица пользователей успешно создана/обновлена")
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
@@ -75,31 +167,45 @@ func (db *DB) Close() {
 	db.Conn.Close()
 }
 
-func (db *DB) GetUserByID(userID int64) *User {
+const userColumns = "id, balance, is_trial, is_active, is_friend, subscription_end_date, COALESCE(refferer_id, 0), " +
+	"username, first_name, last_name, language_code, created_at, last_seen_at, blocked_bot, deleted_at, language"
+
+func scanUser(row interface{ Scan(...interface{}) error }) (User, error) {
 	var user User
-	query := "SELECT id, balance, is_trial, is_active, is_friend, subscription_end_date, config1, config2, config3, refferer_id FROM users WHERE id = ?"
-	err := db.Conn.QueryRow(query, userID).Scan(&user.ID, &user.Balance, &user.IsTrial, &user.IsActive, &user.IsFriend, &user.SubscriptionEndDate, &user.Config1, &user.Config2, &user.Config3, &user.ReffererId)
-	if err != nil {
-		return nil
+	err := row.Scan(&user.ID, &user.Balance, &user.IsTrial, &user.IsActive, &user.IsFriend, &user.SubscriptionEndDate, &user.ReffererId,
+		&user.Username, &user.FirstName, &user.LastName, &user.LanguageCode, &user.CreatedAt, &user.LastSeenAt, &user.BlockedBot, &user.DeletedAt, &user.Language)
+	return user, err
+}
+
+func (db *DB) GetUserByID(ctx context.Context, userID int64) (User, error) {
+	user, err := scanUser(db.queryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = ?", userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
 	}
-	return &user
+	return user, err
 }
 
-func (db *DB) CreateUser(userID int64, trialDays int) error {
-	trialEnd := time.Now().AddDate(0, 0, trialDays) // добавляем дни пробного периода
-	query := "INSERT INTO users (id, balance, is_trial, is_active, is_friend, subscription_end_date, config1, config2, config3, refferer_id) VALUES (?, 0, TRUE, TRUE, FALSE, ?, '', '', '', 0)"
-	_, err := db.Conn.Exec(query, userID, trialEnd)
-	return err
+// CreateUser создаёт пользователя с пробным периодом на trialDays дней.
+func (db *DB) CreateUser(ctx context.Context, userID int64, trialDays int) error {
+	now := time.Now().UTC()
+	trial := Subscription{UserID: userID, Source: SourceTrial, StartsAt: now, EndsAt: now.AddDate(0, 0, trialDays)} // добавляем дни пробного периода
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		query := "INSERT INTO users (id, balance, is_trial, is_active, is_friend, subscription_end_date, refferer_id, created_at, last_seen_at) VALUES (?, 0, TRUE, TRUE, FALSE, ?, 0, ?, ?)"
+		if _, err := tx.exec(ctx, query, userID, trial.EndsAt, now, now); err != nil {
+			return err
+		}
+		return insertSubscription(ctx, tx, &trial)
+	})
 }
 
-func (db *DB) UpdateUserBalance(userID int64, amount float64) error {
+func (db *DB) UpdateUserBalance(ctx context.Context, userID int64, amount float64) error {
 	query := "UPDATE users SET balance = balance + ? WHERE id = ?"
-	_, err := db.Conn.Exec(query, amount, userID)
-	return err
+	return db.execAffecting(ctx, query, amount, userID)
 }
 
-func (db *DB) GetAllUsers() ([]User, error) {
-	rows, err := db.Conn.Query("SELECT id, balance, is_trial, is_active, is_friend, subscription_end_date, config1, config2, config3, refferer_id FROM users")
+func (db *DB) GetAllUsers(ctx context.Context) ([]User, error) {
+	rows, err := db.query(ctx, "SELECT "+userColumns+" FROM users")
 	if err != nil {
 		return nil, err
 	}
@@ -107,81 +213,113 @@ func (db *DB) GetAllUsers() ([]User, error) {
 
 	var users []User
 	for rows.Next() {
-		var user User
-		err := rows.Scan(&user.ID, &user.Balance, &user.IsTrial, &user.IsActive, &user.IsFriend, &user.SubscriptionEndDate, &user.Config1, &user.Config2, &user.Config3, &user.ReffererId)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
 
-	return users, nil
-}
-
-func (db *DB) UpdateUserConfig(userID int64, configIndex int, value string) error {
-	var query string
-	switch configIndex {
-	case 1:
-		query = "UPDATE users SET config1 = ? WHERE id = ?"
-	case 2:
-		query = "UPDATE users SET config2 = ? WHERE id = ?"
-	case 3:
-		query = "UPDATE users SET config3 = ? WHERE id = ?"
-	default:
-		return fmt.Errorf("некорректный индекс конфига: %d", configIndex)
-	}
-	_, err := db.Conn.Exec(query, value, userID)
-	return err
-}
-
-func (db *DB) GetUserConfig(userID int64, configIndex int) string {
-	var query string
-	var config string
-	switch configIndex {
-	case 1:
-		query = "SELECT config1 FROM users WHERE id = ?"
-	case 2:
-		query = "SELECT config2 FROM users WHERE id = ?"
-	case 3:
-		query = "SELECT config3 FROM users WHERE id = ?"
-	default:
-		log.Printf("Неправильный индекс")
-		return ""
-	}
-	err := db.Conn.QueryRow(query, userID).Scan(&config)
+	return users, rows.Err()
+}
+
+// UsersExpiringBetween передаёт в fn активных пользователей (кроме друзей),
+// чья подписка заканчивается в интервале [from, to). Строки читаются
+// потоково, без загрузки всей выборки в память; ошибка fn прерывает обход.
+func (db *DB) UsersExpiringBetween(ctx context.Context, from, to time.Time, fn func(User) error) error {
+	query := "SELECT " + userColumns + " FROM users WHERE is_active = TRUE AND is_friend = FALSE" +
+		" AND subscription_end_date >= ? AND subscription_end_date < ? ORDER BY subscription_end_date"
+	return db.eachUser(ctx, fn, query, from.UTC(), to.UTC())
+}
+
+// ExpiredActiveUsers передаёт в fn активных пользователей (кроме друзей),
+// чья подписка закончилась к моменту now.
+func (db *DB) ExpiredActiveUsers(ctx context.Context, now time.Time, fn func(User) error) error {
+	query := "SELECT " + userColumns + " FROM users WHERE is_active = TRUE AND is_friend = FALSE" +
+		" AND subscription_end_date < ? ORDER BY subscription_end_date"
+	return db.eachUser(ctx, fn, query, now.UTC())
+}
+
+// CountActiveUsers возвращает количество активных пользователей, кроме друзей.
+func (db *DB) CountActiveUsers(ctx context.Context) (int, error) {
+	var count int
+	err := db.queryRow(ctx, "SELECT COUNT(*) FROM users WHERE is_active = TRUE AND is_friend = FALSE").Scan(&count)
+	return count, err
+}
+
+func (db *DB) eachUser(ctx context.Context, fn func(User) error, query string, args ...interface{}) error {
+	rows, err := db.query(ctx, query, args...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Если пользователь не найден, возвращаем пустую строку
-			return ""
-		}
-		// Логируем ошибку, если произошла другая проблема
-		log.Printf("Ошибка при получении конфига пользователя с ID %d: %v", userID, err)
-		return ""
+		return err
 	}
+	defer rows.Close()
 
-	return config
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
-func (db *DB) UpdateTrialStatus(userID int64, isTrial bool) error {
+func (db *DB) UpdateTrialStatus(ctx context.Context, userID int64, isTrial bool) error {
 	query := "UPDATE users SET is_trial = ? WHERE id = ?"
-	_, err := db.Conn.Exec(query, isTrial, userID)
-	return err
+	return db.execAffecting(ctx, query, isTrial, userID)
 }
 
-func (db *DB) UpdateActiveStatus(userID int64, isActive bool) error {
+func (db *DB) UpdateActiveStatus(ctx context.Context, userID int64, isActive bool) error {
 	query := "UPDATE users SET is_active = ? WHERE id = ?"
-	_, err := db.Conn.Exec(query, isActive, userID)
-	return err
+	return db.execAffecting(ctx, query, isActive, userID)
 }
 
-func (db *DB) UpdateSubscriptionEndDate(userID int64, endDate time.Time) error {
-	query := "UPDATE users SET subscription_end_date = ? WHERE id = ?"
-	_, err := db.Conn.Exec(query, endDate, userID)
-	return err
+// UpdateSubscriptionEndDate вручную переносит окончание подписки на endDate,
+// подгоняя под неё периоды пользователя (см. setSubscriptionEnd).
+func (db *DB) UpdateSubscriptionEndDate(ctx context.Context, userID int64, endDate time.Time) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		if err := tx.userExists(ctx, userID); err != nil {
+			return err
+		}
+		return setSubscriptionEnd(ctx, tx, userID, endDate)
+	})
 }
 
-func (db *DB) UpdateReffererID(reffererID int64, userID int64) error {
+func (db *DB) UpdateReffererID(ctx context.Context, reffererID int64, userID int64) error {
 	query := "UPDATE users SET refferer_id = ? WHERE id = ?"
-	_, err := db.Conn.Exec(query, reffererID, userID)
-	return err
+	return db.execAffecting(ctx, query, reffererID, userID)
+}
+
+// GetUserByUsername ищет пользователя по @username без учёта регистра.
+func (db *DB) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	username = strings.TrimPrefix(username, "@")
+	if username == "" {
+		return User{}, ErrNotFound
+	}
+	user, err := scanUser(db.queryRow(ctx, "SELECT "+userColumns+" FROM users WHERE LOWER(username) = LOWER(?)", username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// TouchUser сохраняет актуальный профиль Telegram и время последнего
+// обращения. Раз пользователь пишет боту, он его не блокирует. Профиль
+// удалённых пользователей не восстанавливается. Время, как и остальные
+// даты пользователя, хранится в UTC.
+func (db *DB) TouchUser(ctx context.Context, p Profile) error {
+	query := "UPDATE users SET username = ?, first_name = ?, last_name = ?, language_code = ?, last_seen_at = ?, blocked_bot = FALSE WHERE id = ? AND deleted_at IS NULL"
+	return db.execAffecting(ctx, query, p.Username, p.FirstName, p.LastName, p.LanguageCode, time.Now().UTC(), p.UserID)
+}
+
+// SetUserLanguage сохраняет выбранный пользователем язык интерфейса.
+func (db *DB) SetUserLanguage(ctx context.Context, userID int64, lang string) error {
+	return db.execAffecting(ctx, "UPDATE users SET language = ? WHERE id = ? AND deleted_at IS NULL", lang, userID)
+}
+
+func (db *DB) SetBlockedBot(ctx context.Context, userID int64, blocked bool) error {
+	query := "UPDATE users SET blocked_bot = ? WHERE id = ?"
+	return db.execAffecting(ctx, query, blocked, userID)
 }
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Статусы устройства.
+const (
+	DeviceStatusActive   = "active"
+	DeviceStatusDisabled = "disabled"
+)
+
+// Device — конфиг пользователя для одного устройства. Каждому устройству
+// соответствует отдельный пользователь Marzban.
+type Device struct {
+	ID              int64
+	UserID          int64
+	Slot            int
+	Name            string
+	MarzbanUsername string
+	Location        string
+	Protocol        string
+	Link            string
+	SubscriptionURL string
+	CreatedAt       time.Time
+	Status          string
+}
+
+const deviceColumns = "id, user_id, slot, name, marzban_username, location, protocol, link, subscription_url, created_at, status"
+
+func scanDevice(row interface{ Scan(...interface{}) error }) (Device, error) {
+	var d Device
+	err := row.Scan(&d.ID, &d.UserID, &d.Slot, &d.Name, &d.MarzbanUsername, &d.Location, &d.Protocol, &d.Link, &d.SubscriptionURL, &d.CreatedAt, &d.Status)
+	return d, err
+}
+
+// GetUserDevices возвращает устройства пользователя, упорядоченные по номеру слота.
+func (db *DB) GetUserDevices(ctx context.Context, userID int64) ([]Device, error) {
+	rows, err := db.query(ctx, "SELECT "+deviceColumns+" FROM devices WHERE user_id = ? ORDER BY slot", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []Device
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+
+	return devices, rows.Err()
+}
+
+// GetDevice возвращает устройство пользователя в указанном слоте или
+// ErrNotFound, если слот свободен.
+func (db *DB) GetDevice(ctx context.Context, userID int64, slot int) (Device, error) {
+	d, err := scanDevice(db.queryRow(ctx, "SELECT "+deviceColumns+" FROM devices WHERE user_id = ? AND slot = ?", userID, slot))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Device{}, ErrNotFound
+	}
+	return d, err
+}
+
+// GetDeviceByMarzbanUsername возвращает устройство по имени пользователя Marzban.
+func (db *DB) GetDeviceByMarzbanUsername(ctx context.Context, username string) (Device, error) {
+	d, err := scanDevice(db.queryRow(ctx, "SELECT "+deviceColumns+" FROM devices WHERE marzban_username = ?", username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Device{}, ErrNotFound
+	}
+	return d, err
+}
+
+// FreeDeviceSlot возвращает наименьший незанятый номер слота пользователя.
+func (db *DB) FreeDeviceSlot(ctx context.Context, userID int64) (int, error) {
+	devices, err := db.GetUserDevices(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return freeSlot(devices), nil
+}
+
+// freeSlot находит первый пропуск в отсортированных по слоту устройствах.
+func freeSlot(devices []Device) int {
+	slot := 1
+	for _, d := range devices {
+		if d.Slot != slot {
+			break
+		}
+		slot++
+	}
+	return slot
+}
+
+// CreateDevice сохраняет новое устройство и заполняет его ID.
+func (db *DB) CreateDevice(ctx context.Context, d *Device) error {
+	return insertDevice(ctx, db, d)
+}
+
+// rowQuerier — общий для DB и Tx метод, через который выполняются вставки.
+type rowQuerier interface {
+	queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertDevice(ctx context.Context, q rowQuerier, d *Device) error {
+	if d.Status == "" {
+		d.Status = DeviceStatusActive
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+
+	// RETURNING поддерживают и SQLite, и PostgreSQL, в отличие от LastInsertId
+	query := "INSERT INTO devices (user_id, slot, name, marzban_username, location, protocol, link, subscription_url, created_at, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id"
+	return q.queryRow(ctx, query, d.UserID, d.Slot, d.Name, d.MarzbanUsername, d.Location, d.Protocol, d.Link, d.SubscriptionURL, d.CreatedAt, d.Status).Scan(&d.ID)
+}
+
+// UpdateDeviceLink сохраняет новые ссылки устройства после перевыпуска ключа.
+func (db *DB) UpdateDeviceLink(ctx context.Context, deviceID int64, link, subscriptionURL string) error {
+	query := "UPDATE devices SET link = ?, subscription_url = ? WHERE id = ?"
+	return db.execAffecting(ctx, query, link, subscriptionURL, deviceID)
+}
+
+func (db *DB) UpdateDeviceStatus(ctx context.Context, deviceID int64, status string) error {
+	query := "UPDATE devices SET status = ? WHERE id = ?"
+	return db.execAffecting(ctx, query, status, deviceID)
+}
+
+func (db *DB) DeleteDevice(ctx context.Context, deviceID int64) error {
+	query := "DELETE FROM devices WHERE id = ?"
+	return db.execAffecting(ctx, query, deviceID)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialect описывает различия SQL между поддерживаемыми СУБД. Запросы в
+// пакете пишутся с плейсхолдерами "?" и переписываются под СУБД в rebind.
+type dialect struct {
+	// name совпадает с именем каталога миграций: migrations/<name>
+	name string
+	// driver — имя драйвера database/sql
+	driver string
+	// numbered — плейсхолдеры вида $1, $2 вместо ?
+	numbered bool
+	// timestampType — тип колонки для времени в служебных таблицах
+	timestampType string
+	// maxOpenConns ограничивает пул соединений
+	maxOpenConns int
+	// dsnParams добавляются к строке подключения и применяются
+	// к каждому новому соединению пула
+	dsnParams []string
+	// snapshotIsolation — уровень изоляции, при котором все запросы
+	// транзакции видят один снимок базы
+	snapshotIsolation sql.IsolationLevel
+}
+
+var (
+	sqliteDialect = dialect{
+		name:          "sqlite",
+		driver:        "sqlite",
+		timestampType: "DATETIME",
+		// Писать одновременно может только одно соединение, остальные
+		// ждут busy_timeout; небольшой пул оставляет место для чтения
+		maxOpenConns: 4,
+		dsnParams: []string{
+			// WAL позволяет читать во время записи
+			"_pragma=journal_mode(WAL)",
+			"_pragma=busy_timeout(5000)",
+			"_pragma=foreign_keys(1)",
+			"_pragma=synchronous(NORMAL)",
+			// Транзакция сразу берёт блокировку записи: иначе при повышении
+			// блокировки чтения до записи SQLite возвращает SQLITE_BUSY,
+			// не дожидаясь busy_timeout
+			"_txlock=immediate",
+		},
+	}
+	postgresDialect = dialect{
+		name:          "postgres",
+		driver:        "postgres",
+		numbered:      true,
+		timestampType: "TIMESTAMPTZ",
+		maxOpenConns:  10,
+		// По умолчанию READ COMMITTED: каждый запрос видит свой снимок
+		snapshotIsolation: sql.LevelRepeatableRead,
+	}
+)
+
+// dialectFor возвращает диалект по значению database.driver из конфигурации.
+// Пустое значение означает SQLite.
+func dialectFor(driver string) (dialect, error) {
+	switch strings.ToLower(driver) {
+	case "", "sqlite", "sqlite3":
+		return sqliteDialect, nil
+	case "postgres", "postgresql":
+		return postgresDialect, nil
+	default:
+		return dialect{}, fmt.Errorf("неподдерживаемый драйвер базы данных: %q", driver)
+	}
+}
+
+// withParams добавляет к DSN параметры соединения диалекта.
+// Параметры, уже заданные в DSN, не дублируются.
+func (d dialect) withParams(dsn string) string {
+	for _, param := range d.dsnParams {
+		if strings.Contains(dsn, param) {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + param
+	}
+	return dsn
+}
+
+// rebind заменяет плейсхолдеры "?" на нумерованные, если этого требует СУБД.
+// Вопросительные знаки внутри строковых литералов не затрагиваются.
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			b.WriteRune(r)
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
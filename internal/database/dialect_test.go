@@ -0,0 +1,57 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	query := "UPDATE users SET note = '?' WHERE id = ? AND slot = ?"
+
+	if got := sqliteDialect.rebind(query); got != query {
+		t.Errorf("sqlite rebind = %q", got)
+	}
+
+	want := "UPDATE users SET note = '?' WHERE id = $1 AND slot = $2"
+	if got := postgresDialect.rebind(query); got != want {
+		t.Errorf("postgres rebind = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultSQLiteDSN(t *testing.T) {
+	touch := func(path string) {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("fresh", func(t *testing.T) {
+		dir := t.TempDir()
+		path, legacy := filepath.Join(dir, "data", "vpn-bot.db"), filepath.Join(dir, "vpn-bot.db")
+		got, err := defaultSQLiteDSN(path, legacy)
+		if err != nil || got != path {
+			t.Errorf("got %q, %v, want %q", got, err, path)
+		}
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		dir := t.TempDir()
+		path, legacy := filepath.Join(dir, "data", "vpn-bot.db"), filepath.Join(dir, "vpn-bot.db")
+		touch(legacy)
+		got, err := defaultSQLiteDSN(path, legacy)
+		if err != nil || got != legacy {
+			t.Errorf("got %q, %v, want legacy %q", got, err, legacy)
+		}
+	})
+
+	t.Run("both", func(t *testing.T) {
+		dir := t.TempDir()
+		path, legacy := filepath.Join(dir, "new.db"), filepath.Join(dir, "vpn-bot.db")
+		touch(legacy)
+		touch(path)
+		if got, err := defaultSQLiteDSN(path, legacy); err == nil {
+			t.Errorf("got %q, want error", got)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы событий журнала.
+const (
+	EventTrialStarted         = "trial_started"
+	EventDeviceCreated        = "device_created"
+	EventDeviceDeleted        = "device_deleted"
+	EventDeviceRevoked        = "device_revoked"
+	EventDeviceDisabled       = "device_disabled"
+	EventPaymentCredited      = "payment_credited"
+	EventSubscriptionExtended = "subscription_extended"
+	EventUserDeactivated      = "user_deactivated"
+	EventAdminAction          = "admin_action"
+	EventUserErased           = "user_erased"
+	EventTicketOpened         = "ticket_opened"
+	EventTicketClosed         = "ticket_closed"
+)
+
+// ActorSystem — инициатор событий, которые выполняет сам бот по расписанию.
+const ActorSystem = "system"
+
+// ActorUser и ActorAdmin формируют инициатора события из ID в Telegram.
+func ActorUser(userID int64) string  { return fmt.Sprintf("user:%d", userID) }
+func ActorAdmin(userID int64) string { return fmt.Sprintf("admin:%d", userID) }
+
+// ActorProvider — платёжный провайдер, приславший уведомление.
+func ActorProvider(provider string) string {
+	if provider == "" {
+		return "provider"
+	}
+	return "provider:" + provider
+}
+
+// Event — запись журнала об изменении состояния пользователя.
+// Операции, меняющие несколько таблиц (CreatePayment, DeactivateUser,
+// ActivateSubscription), пишут событие в той же транзакции.
+type Event struct {
+	ID        int64
+	Type      string
+	Actor     string
+	UserID    int64 // 0, если событие не относится к пользователю
+	Details   map[string]interface{}
+	CreatedAt time.Time
+}
+
+// EventFilter ограничивает выборку ListEvents. Нулевые поля не фильтруют.
+type EventFilter struct {
+	UserID int64
+	Type   string
+	Since  time.Time
+	Until  time.Time
+	// Limit — максимальное число событий, по умолчанию defaultEventLimit
+	Limit int
+}
+
+const defaultEventLimit = 100
+
+func (f EventFilter) limit() int {
+	if f.Limit <= 0 {
+		return defaultEventLimit
+	}
+	return f.Limit
+}
+
+// match используется MemoryStore и повторяет условия SQL-запроса.
+func (f EventFilter) match(e Event) bool {
+	return (f.UserID == 0 || e.UserID == f.UserID) &&
+		(f.Type == "" || e.Type == f.Type) &&
+		(f.Since.IsZero() || !e.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.CreatedAt.Before(f.Until))
+}
+
+const eventColumns = "id, type, actor, COALESCE(user_id, 0), details, created_at"
+
+func (db *DB) RecordEvent(ctx context.Context, e *Event) error {
+	return insertEvent(ctx, db, e)
+}
+
+func insertEvent(ctx context.Context, q rowQuerier, e *Event) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	// Время храним в UTC, чтобы сравнение в SQLite не зависело от часового пояса
+	e.CreatedAt = e.CreatedAt.UTC()
+
+	details := []byte("{}")
+	if len(e.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(e.Details); err != nil {
+			return fmt.Errorf("ошибка сериализации события %s: %w", e.Type, err)
+		}
+	}
+
+	var userID sql.NullInt64
+	if e.UserID != 0 {
+		userID = sql.NullInt64{Int64: e.UserID, Valid: true}
+	}
+
+	query := "INSERT INTO events (type, actor, user_id, details, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id"
+	return q.queryRow(ctx, query, e.Type, e.Actor, userID, string(details), e.CreatedAt).Scan(&e.ID)
+}
+
+// ListEvents возвращает события по фильтру, новые первыми.
+func (db *DB) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	var where []string
+	var args []interface{}
+	if f.UserID != 0 {
+		where = append(where, "user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if f.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, f.Type)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, f.Until.UTC())
+	}
+
+	query := "SELECT " + eventColumns + " FROM events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, f.limit())
+
+	rows, err := db.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var details string
+		if err := rows.Scan(&e.ID, &e.Type, &e.Actor, &e.UserID, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(details), &e.Details); err != nil {
+			return nil, fmt.Errorf("ошибка чтения события %d: %w", e.ID, err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		old := &Event{Type: EventTrialStarted, Actor: ActorUser(1), UserID: 1, CreatedAt: time.Now().Add(-48 * time.Hour)}
+		if err := db.RecordEvent(ctx, old); err != nil {
+			t.Fatalf("RecordEvent: %v", err)
+		}
+		if err := db.RecordEvent(ctx, &Event{Type: EventAdminAction, Actor: ActorAdmin(99), Details: map[string]interface{}{"command": "check"}}); err != nil {
+			t.Fatalf("RecordEvent: %v", err)
+		}
+		// Операции с несколькими таблицами пишут событие сами
+		if err := db.CreatePayment(ctx, &Payment{UserID: 1, Amount: 100, Provider: "test", ExternalID: "pay-1"}); err != nil {
+			t.Fatalf("CreatePayment: %v", err)
+		}
+		if err := db.DeactivateUser(ctx, 1); err != nil {
+			t.Fatalf("DeactivateUser: %v", err)
+		}
+
+		events, err := db.ListEvents(ctx, EventFilter{UserID: 1})
+		if err != nil {
+			t.Fatalf("ListEvents: %v", err)
+		}
+		var types []string
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		want := []string{EventUserDeactivated, EventPaymentCredited, EventTrialStarted}
+		if len(types) != len(want) {
+			t.Fatalf("user events = %v, want %v", types, want)
+		}
+		for i := range want {
+			if types[i] != want[i] {
+				t.Fatalf("user events = %v, want %v", types, want)
+			}
+		}
+
+		payment := events[1]
+		if payment.Actor != "provider:test" || payment.Details["external_id"] != "pay-1" {
+			t.Errorf("payment event = %+v", payment)
+		}
+
+		recent, err := db.ListEvents(ctx, EventFilter{Since: time.Now().Add(-time.Hour)})
+		if err != nil {
+			t.Fatalf("ListEvents since: %v", err)
+		}
+		if len(recent) != 3 {
+			t.Errorf("recent events = %+v, want 3", recent)
+		}
+
+		admin, err := db.ListEvents(ctx, EventFilter{Type: EventAdminAction, Limit: 1})
+		if err != nil {
+			t.Fatalf("ListEvents by type: %v", err)
+		}
+		if len(admin) != 1 || admin[0].UserID != 0 || admin[0].Details["command"] != "check" {
+			t.Errorf("admin events = %+v", admin)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExpiryQueries(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+		now := time.Now()
+
+		ends := map[int64]time.Time{
+			1: now.Add(-24 * time.Hour), // истекла
+			2: now.Add(-time.Hour),      // истекла
+			3: now.Add(60 * time.Hour),  // истекает через 2.5 дня
+			4: now.Add(10 * 24 * time.Hour),
+			5: now.Add(-time.Hour), // друг, не отключается
+			6: now.Add(-time.Hour), // уже отключен
+		}
+		for id, end := range ends {
+			if err := db.CreateUser(ctx, id, 7); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			if err := db.UpdateSubscriptionEndDate(ctx, id, end); err != nil {
+				t.Fatalf("UpdateSubscriptionEndDate: %v", err)
+			}
+		}
+		markFriend(t, db, 5)
+		if err := db.UpdateActiveStatus(ctx, 6, false); err != nil {
+			t.Fatalf("UpdateActiveStatus: %v", err)
+		}
+
+		collect := func(iterate func(fn func(User) error) error) []int64 {
+			t.Helper()
+			var ids []int64
+			if err := iterate(func(u User) error {
+				ids = append(ids, u.ID)
+				return nil
+			}); err != nil {
+				t.Fatalf("iterate: %v", err)
+			}
+			return ids
+		}
+
+		expired := collect(func(fn func(User) error) error { return db.ExpiredActiveUsers(ctx, now, fn) })
+		if len(expired) != 2 || expired[0] != 1 || expired[1] != 2 {
+			t.Errorf("ExpiredActiveUsers = %v, want [1 2]", expired)
+		}
+
+		expiring := collect(func(fn func(User) error) error {
+			return db.UsersExpiringBetween(ctx, now.Add(48*time.Hour), now.Add(72*time.Hour), fn)
+		})
+		if len(expiring) != 1 || expiring[0] != 3 {
+			t.Errorf("UsersExpiringBetween = %v, want [3]", expiring)
+		}
+
+		count, err := db.CountActiveUsers(ctx)
+		if err != nil || count != 4 {
+			t.Errorf("CountActiveUsers = %d, %v; want 4", count, err)
+		}
+
+		// Ошибка обработчика прерывает обход
+		stop := errors.New("stop")
+		calls := 0
+		err = db.ExpiredActiveUsers(ctx, now, func(User) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("ExpiredActiveUsers with failing fn: err = %v, calls = %d", err, calls)
+		}
+	})
+}
+
+// markFriend отмечает пользователя как друга: в репозиториях нет
+// такой операции, флаг выставляется администратором вручную.
+func markFriend(t *testing.T, db Store, userID int64) {
+	t.Helper()
+
+	switch db := db.(type) {
+	case *DB:
+		if _, err := db.exec(context.Background(), "UPDATE users SET is_friend = TRUE WHERE id = ?", userID); err != nil {
+			t.Fatalf("mark friend: %v", err)
+		}
+	case *MemoryStore:
+		if err := db.updateUser(userID, func(u *User) { u.IsFriend = true }); err != nil {
+			t.Fatalf("mark friend: %v", err)
+		}
+	}
+}
@@ -0,0 +1,758 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore — хранилище в памяти с тем же поведением, что и DB.
+// Предназначено для тестов логики обработчиков без настоящей базы.
+type MemoryStore struct {
+	mu       sync.Mutex
+	users    map[int64]User
+	devices  map[int64]Device
+	payments []Payment
+	events   []Event
+	subs     []Subscription
+	tickets  []SupportTicket
+	// supportMessages связывает сообщение в чате поддержки с обращением
+	supportMessages map[[2]int64]int64
+	states          map[int64]UserState
+	nextID          int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:           make(map[int64]User),
+		devices:         make(map[int64]Device),
+		supportMessages: make(map[[2]int64]int64),
+		states:          make(map[int64]UserState),
+	}
+}
+
+func (m *MemoryStore) Close() {}
+
+func (m *MemoryStore) newID() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+// updateUser применяет fn к пользователю или возвращает ErrNotFound.
+func (m *MemoryStore) updateUser(userID int64, fn func(*User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&user)
+	m.users[userID] = user
+	return nil
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, userID int64) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *MemoryStore) GetAllUsers(ctx context.Context) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (m *MemoryStore) UsersExpiringBetween(ctx context.Context, from, to time.Time, fn func(User) error) error {
+	return m.eachUser(fn, func(u User) bool {
+		end := u.SubscriptionEndDate.Time
+		return !end.Before(from) && end.Before(to)
+	})
+}
+
+func (m *MemoryStore) ExpiredActiveUsers(ctx context.Context, now time.Time, fn func(User) error) error {
+	return m.eachUser(fn, func(u User) bool {
+		return u.SubscriptionEndDate.Time.Before(now)
+	})
+}
+
+func (m *MemoryStore) CountActiveUsers(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, u := range m.users {
+		if u.IsActive && !u.IsFriend {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// eachUser передаёт в fn активных пользователей (кроме друзей) с
+// заполненной датой подписки, для которых match возвращает true. Выборка
+// копируется до вызова fn, чтобы fn могла обращаться к хранилищу.
+func (m *MemoryStore) eachUser(fn func(User) error, match func(User) bool) error {
+	m.mu.Lock()
+	var users []User
+	for _, u := range m.users {
+		if u.IsActive && !u.IsFriend && u.SubscriptionEndDate.Valid && match(u) {
+			users = append(users, u)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].SubscriptionEndDate.Time.Before(users[j].SubscriptionEndDate.Time)
+	})
+	for _, u := range users {
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, userID int64, trialDays int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; ok {
+		return fmt.Errorf("пользователь %d уже существует", userID)
+	}
+	now := time.Now()
+	m.users[userID] = User{
+		ID:         userID,
+		IsTrial:    true,
+		IsActive:   true,
+		CreatedAt:  sql.NullTime{Time: now, Valid: true},
+		LastSeenAt: sql.NullTime{Time: now, Valid: true},
+	}
+	m.addSubscription(&Subscription{UserID: userID, Source: SourceTrial, StartsAt: now, EndsAt: now.AddDate(0, 0, trialDays)})
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserBalance(ctx context.Context, userID int64, amount float64) error {
+	return m.updateUser(userID, func(u *User) { u.Balance += amount })
+}
+
+func (m *MemoryStore) UpdateTrialStatus(ctx context.Context, userID int64, isTrial bool) error {
+	return m.updateUser(userID, func(u *User) { u.IsTrial = isTrial })
+}
+
+func (m *MemoryStore) UpdateActiveStatus(ctx context.Context, userID int64, isActive bool) error {
+	return m.updateUser(userID, func(u *User) { u.IsActive = isActive })
+}
+
+func (m *MemoryStore) UpdateSubscriptionEndDate(ctx context.Context, userID int64, endDate time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	latest := -1
+	for i := range m.subs {
+		s := &m.subs[i]
+		if s.UserID != userID {
+			continue
+		}
+		if s.StartsAt.After(endDate) {
+			s.StartsAt = endDate
+		}
+		if s.EndsAt.After(endDate) {
+			s.EndsAt = endDate
+		}
+		if latest < 0 || !s.EndsAt.Before(m.subs[latest].EndsAt) {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		start := time.Now()
+		if endDate.Before(start) {
+			start = endDate
+		}
+		m.addSubscription(&Subscription{UserID: userID, Source: SourceAdmin, StartsAt: start, EndsAt: endDate})
+		return nil
+	}
+	m.subs[latest].EndsAt = endDate
+	m.syncSubscriptionEnd(userID)
+	return nil
+}
+
+func (m *MemoryStore) UpdateReffererID(ctx context.Context, reffererID int64, userID int64) error {
+	return m.updateUser(userID, func(u *User) { u.ReffererId = reffererID })
+}
+
+func (m *MemoryStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	username = strings.TrimPrefix(username, "@")
+	for _, user := range m.users {
+		if user.Username != "" && strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (m *MemoryStore) TouchUser(ctx context.Context, p Profile) error {
+	if user, err := m.GetUserByID(ctx, p.UserID); err == nil && user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	return m.updateUser(p.UserID, func(u *User) {
+		u.Username = p.Username
+		u.FirstName = p.FirstName
+		u.LastName = p.LastName
+		u.LanguageCode = p.LanguageCode
+		u.LastSeenAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		u.BlockedBot = false
+	})
+}
+
+func (m *MemoryStore) SetUserLanguage(ctx context.Context, userID int64, lang string) error {
+	if user, err := m.GetUserByID(ctx, userID); err == nil && user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	return m.updateUser(userID, func(u *User) { u.Language = lang })
+}
+
+func (m *MemoryStore) SetBlockedBot(ctx context.Context, userID int64, blocked bool) error {
+	return m.updateUser(userID, func(u *User) { u.BlockedBot = blocked })
+}
+
+func (m *MemoryStore) DeactivateUser(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	deleted := 0
+	for id, d := range m.devices {
+		if d.UserID == userID {
+			delete(m.devices, id)
+			deleted++
+		}
+	}
+	user.IsTrial = false
+	user.IsActive = false
+	m.users[userID] = user
+
+	m.appendEvent(&Event{
+		Type:    EventUserDeactivated,
+		Actor:   ActorSystem,
+		UserID:  userID,
+		Details: map[string]interface{}{"devices_deleted": deleted},
+	})
+	return nil
+}
+
+func (m *MemoryStore) ActivateSubscription(ctx context.Context, userID int64, source string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if start := periodStart(time.Now(), user.SubscriptionEndDate); until.After(start) {
+		m.addSubscription(&Subscription{UserID: userID, Source: source, StartsAt: start, EndsAt: until})
+		user = m.users[userID]
+	}
+	user.IsActive = true
+	user.IsTrial = false
+	m.users[userID] = user
+
+	m.appendEvent(&Event{
+		Type:    EventSubscriptionExtended,
+		Actor:   ActorSystem,
+		UserID:  userID,
+		Details: map[string]interface{}{"source": source, "until": until.UTC().Format(time.RFC3339)},
+	})
+	return nil
+}
+
+func (m *MemoryStore) GrantSubscription(ctx context.Context, userID int64, source, plan string, days int) (Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	start := periodStart(time.Now(), user.SubscriptionEndDate)
+	sub := Subscription{UserID: userID, Source: source, Plan: plan, StartsAt: start, EndsAt: start.AddDate(0, 0, days)}
+	m.addSubscription(&sub)
+
+	user = m.users[userID]
+	user.IsActive = true
+	user.IsTrial = source == SourceTrial
+	m.users[userID] = user
+
+	m.appendEvent(&Event{
+		Type:   EventSubscriptionExtended,
+		Actor:  ActorSystem,
+		UserID: userID,
+		Details: map[string]interface{}{
+			"source": source,
+			"plan":   plan,
+			"days":   days,
+			"until":  sub.EndsAt.UTC().Format(time.RFC3339),
+		},
+	})
+	return sub, nil
+}
+
+func (m *MemoryStore) GetUserSubscriptions(ctx context.Context, userID int64) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var subs []Subscription
+	for _, s := range m.subs {
+		if s.UserID == userID {
+			subs = append(subs, s)
+		}
+	}
+	sort.SliceStable(subs, func(i, j int) bool { return subs[i].StartsAt.Before(subs[j].StartsAt) })
+	return subs, nil
+}
+
+// addSubscription сохраняет период и пересчитывает окончание подписки.
+// Вызывается под m.mu.
+func (m *MemoryStore) addSubscription(s *Subscription) {
+	s.ID = m.newID()
+	s.CreatedAt = time.Now()
+	m.subs = append(m.subs, *s)
+	m.syncSubscriptionEnd(s.UserID)
+}
+
+// syncSubscriptionEnd повторяет одноимённую функцию DB. Вызывается под m.mu.
+func (m *MemoryStore) syncSubscriptionEnd(userID int64) {
+	user, ok := m.users[userID]
+	if !ok {
+		return
+	}
+	user.SubscriptionEndDate = sql.NullTime{}
+	for _, s := range m.subs {
+		if s.UserID == userID && (!user.SubscriptionEndDate.Valid || s.EndsAt.After(user.SubscriptionEndDate.Time)) {
+			user.SubscriptionEndDate = sql.NullTime{Time: s.EndsAt, Valid: true}
+		}
+	}
+	m.users[userID] = user
+}
+
+func (m *MemoryStore) EraseUser(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || user.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	m.users[userID] = User{
+		ID:                  user.ID,
+		Balance:             user.Balance,
+		IsFriend:            user.IsFriend,
+		SubscriptionEndDate: user.SubscriptionEndDate,
+		CreatedAt:           user.CreatedAt,
+		LastSeenAt:          user.LastSeenAt,
+		BlockedBot:          user.BlockedBot,
+		Language:            user.Language,
+		DeletedAt:           sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+
+	for id, d := range m.devices {
+		if d.UserID == userID {
+			delete(m.devices, id)
+		}
+	}
+	delete(m.states, userID)
+	for i := range m.payments {
+		if m.payments[i].UserID == userID && m.payments[i].ExternalID != "" {
+			m.payments[i].ExternalID = erasedExternalID(m.payments[i].ExternalID)
+		}
+	}
+	for i := range m.events {
+		if m.events[i].UserID == userID {
+			m.events[i].Details = nil
+		}
+	}
+
+	m.appendEvent(&Event{Type: EventUserErased, Actor: ActorUser(userID), UserID: userID})
+	return nil
+}
+
+func (m *MemoryStore) GetUserDevices(ctx context.Context, userID int64) ([]Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.userDevices(userID), nil
+}
+
+func (m *MemoryStore) userDevices(userID int64) []Device {
+	var devices []Device
+	for _, d := range m.devices {
+		if d.UserID == userID {
+			devices = append(devices, d)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Slot < devices[j].Slot })
+	return devices
+}
+
+func (m *MemoryStore) GetDevice(ctx context.Context, userID int64, slot int) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.devices {
+		if d.UserID == userID && d.Slot == slot {
+			return d, nil
+		}
+	}
+	return Device{}, ErrNotFound
+}
+
+func (m *MemoryStore) GetDeviceByMarzbanUsername(ctx context.Context, username string) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.devices {
+		if d.MarzbanUsername == username {
+			return d, nil
+		}
+	}
+	return Device{}, ErrNotFound
+}
+
+func (m *MemoryStore) FreeDeviceSlot(ctx context.Context, userID int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return freeSlot(m.userDevices(userID)), nil
+}
+
+func (m *MemoryStore) CreateDevice(ctx context.Context, d *Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createDevice(d)
+}
+
+func (m *MemoryStore) AddDevice(ctx context.Context, d *Device, limit int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[d.UserID]
+	if !ok {
+		return ErrNotFound
+	}
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	if len(m.userDevices(d.UserID)) >= limit {
+		return ErrDeviceLimit
+	}
+	return m.createDevice(d)
+}
+
+func (m *MemoryStore) createDevice(d *Device) error {
+	if _, ok := m.users[d.UserID]; !ok {
+		return fmt.Errorf("пользователь %d не существует", d.UserID)
+	}
+	for _, existing := range m.devices {
+		if existing.UserID == d.UserID && existing.Slot == d.Slot {
+			return fmt.Errorf("слот %d пользователя %d уже занят", d.Slot, d.UserID)
+		}
+		if existing.MarzbanUsername == d.MarzbanUsername {
+			return fmt.Errorf("устройство %s уже существует", d.MarzbanUsername)
+		}
+	}
+
+	if d.Status == "" {
+		d.Status = DeviceStatusActive
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+	d.ID = m.newID()
+	m.devices[d.ID] = *d
+	return nil
+}
+
+// updateDevice применяет fn к устройству или возвращает ErrNotFound.
+func (m *MemoryStore) updateDevice(deviceID int64, fn func(*Device)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[deviceID]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&d)
+	m.devices[deviceID] = d
+	return nil
+}
+
+func (m *MemoryStore) UpdateDeviceLink(ctx context.Context, deviceID int64, link, subscriptionURL string) error {
+	return m.updateDevice(deviceID, func(d *Device) {
+		d.Link = link
+		d.SubscriptionURL = subscriptionURL
+	})
+}
+
+func (m *MemoryStore) UpdateDeviceStatus(ctx context.Context, deviceID int64, status string) error {
+	return m.updateDevice(deviceID, func(d *Device) { d.Status = status })
+}
+
+func (m *MemoryStore) DeleteDevice(ctx context.Context, deviceID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devices[deviceID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.devices, deviceID)
+	return nil
+}
+
+func (m *MemoryStore) CreatePayment(ctx context.Context, p *Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[p.UserID]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.ExternalID != "" {
+		for _, existing := range m.payments {
+			if existing.ExternalID == p.ExternalID || existing.ExternalID == erasedExternalID(p.ExternalID) {
+				return ErrDuplicatePayment
+			}
+		}
+	}
+
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+	p.ID = m.newID()
+	m.payments = append(m.payments, *p)
+
+	user.Balance += p.Amount
+	m.users[p.UserID] = user
+
+	m.appendEvent(paymentEvent(p))
+	return nil
+}
+
+func (m *MemoryStore) GetUserPayments(ctx context.Context, userID int64) ([]Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var payments []Payment
+	for _, p := range m.payments {
+		if p.UserID == userID {
+			payments = append(payments, p)
+		}
+	}
+	return payments, nil
+}
+
+func (m *MemoryStore) RecordEvent(ctx context.Context, e *Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.appendEvent(e)
+	return nil
+}
+
+// appendEvent добавляет событие в журнал; вызывается под m.mu.
+func (m *MemoryStore) appendEvent(e *Event) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	e.CreatedAt = e.CreatedAt.UTC()
+	e.ID = m.newID()
+	m.events = append(m.events, *e)
+}
+
+func (m *MemoryStore) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []Event
+	for i := len(m.events) - 1; i >= 0 && len(events) < f.limit(); i-- {
+		if f.match(m.events[i]) {
+			events = append(events, m.events[i])
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) OpenTicket(ctx context.Context, userID int64) (SupportTicket, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tickets {
+		if t.UserID == userID && t.Status == TicketStatusOpen {
+			return t, false, nil
+		}
+	}
+	if _, ok := m.users[userID]; !ok {
+		return SupportTicket{}, false, ErrNotFound
+	}
+	t := SupportTicket{ID: m.newID(), UserID: userID, Status: TicketStatusOpen, CreatedAt: time.Now()}
+	m.tickets = append(m.tickets, t)
+	m.appendEvent(&Event{
+		Type:    EventTicketOpened,
+		Actor:   ActorUser(userID),
+		UserID:  userID,
+		Details: map[string]interface{}{"ticket_id": t.ID},
+	})
+	return t, true, nil
+}
+
+func (m *MemoryStore) GetOpenTicket(ctx context.Context, userID int64) (SupportTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tickets {
+		if t.UserID == userID && t.Status == TicketStatusOpen {
+			return t, nil
+		}
+	}
+	return SupportTicket{}, ErrNotFound
+}
+
+func (m *MemoryStore) GetTicket(ctx context.Context, ticketID int64) (SupportTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.ticket(ticketID)
+}
+
+// ticket вызывается под m.mu.
+func (m *MemoryStore) ticket(ticketID int64) (SupportTicket, error) {
+	for _, t := range m.tickets {
+		if t.ID == ticketID {
+			return t, nil
+		}
+	}
+	return SupportTicket{}, ErrNotFound
+}
+
+func (m *MemoryStore) AddSupportMessage(ctx context.Context, ticketID, chatID int64, messageID int, fromAgent bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ticket(ticketID); err != nil {
+		return err
+	}
+	key := [2]int64{chatID, int64(messageID)}
+	if _, ok := m.supportMessages[key]; ok {
+		return fmt.Errorf("сообщение %d в чате %d уже привязано к обращению", messageID, chatID)
+	}
+	m.supportMessages[key] = ticketID
+	return nil
+}
+
+func (m *MemoryStore) GetTicketBySupportMessage(ctx context.Context, chatID int64, messageID int) (SupportTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticketID, ok := m.supportMessages[[2]int64{chatID, int64(messageID)}]
+	if !ok {
+		return SupportTicket{}, ErrNotFound
+	}
+	return m.ticket(ticketID)
+}
+
+func (m *MemoryStore) CloseTicket(ctx context.Context, ticketID, closedBy int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, t := range m.tickets {
+		if t.ID != ticketID {
+			continue
+		}
+		if t.Status == TicketStatusClosed {
+			return ErrTicketClosed
+		}
+		m.tickets[i].Status = TicketStatusClosed
+		m.tickets[i].ClosedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		m.tickets[i].ClosedBy = closedBy
+		m.appendEvent(&Event{
+			Type:    EventTicketClosed,
+			Actor:   ActorAdmin(closedBy),
+			UserID:  t.UserID,
+			Details: map[string]interface{}{"ticket_id": ticketID},
+		})
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) SetUserState(ctx context.Context, s UserState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	s.Data = data
+	s.ExpiresAt = s.ExpiresAt.UTC()
+	s.UpdatedAt = time.Now().UTC()
+	m.states[s.UserID] = s
+	return nil
+}
+
+func (m *MemoryStore) GetUserState(ctx context.Context, userID int64) (UserState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.states[userID]
+	if !ok {
+		return UserState{}, ErrNotFound
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) ClearUserState(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.states, userID)
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredStates(ctx context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int
+	for id, s := range m.states {
+		if s.Expired(now) {
+			delete(m.states, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationsFS содержит up-миграции схемы, по каталогу на каждую СУБД:
+// migrations/sqlite и migrations/postgres. Имя файла начинается с номера
+// версии: 0001_create_users.up.sql. Применённые миграции не редактируются —
+// любое изменение схемы оформляется новым файлом со следующим номером,
+// одинаковым для всех СУБД.
+//
+//go:embed migrations/*/*.up.sql
+var migrationsFS embed.FS
+
+// dialectMigrations возвращает миграции для указанной СУБД.
+func dialectMigrations(d dialect) (fs.FS, error) {
+	return fs.Sub(migrationsFS, "migrations/"+d.name)
+}
+
+// migrationLockID — ключ advisory-блокировки PostgreSQL на время миграций.
+const migrationLockID = 7_340_001
+
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations читает миграции из корня fsys и сортирует их по версии.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	files, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".up.sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("некорректное имя миграции %s", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("миграции %s и %s имеют одинаковую версию %d", other, name, version)
+		}
+		seen[version] = name
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// migrate применяет недостающие миграции, каждую в своей транзакции.
+// Если база уже обновлена более новой версией бота, запуск прерывается,
+// чтобы старый бинарник не работал со схемой, которую не понимает.
+func migrate(db *sql.DB, d dialect, fsys fs.FS) error {
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения миграций: %w", err)
+	}
+
+	// Все шаги выполняются на одном соединении, чтобы блокировка
+	// PostgreSQL удерживалась до конца миграций
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if d.name == postgresDialect.name {
+		// Несколько реплик бота могут стартовать одновременно — миграции
+		// применяет только та, что первой получила блокировку
+		if _, err := conn.ExecContext(ctx, d.rebind("SELECT pg_advisory_lock(?)"), migrationLockID); err != nil {
+			return fmt.Errorf("ошибка получения блокировки миграций: %w", err)
+		}
+		defer conn.ExecContext(ctx, d.rebind("SELECT pg_advisory_unlock(?)"), migrationLockID)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at `+d.timestampType+` NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы миграций: %w", err)
+	}
+
+	var current int
+	err = conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return fmt.Errorf("версия схемы базы данных (%d) новее, чем поддерживает бот (%d)", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, conn, d, m); err != nil {
+			return fmt.Errorf("ошибка применения миграции %s: %w", m.Name, err)
+		}
+		log.Printf("Применена миграция %s", m.Name)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, d dialect, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"), m.Version, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const schemaVersionQuery = "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"
+
+// SchemaVersion возвращает номер последней применённой миграции.
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	err := db.queryRow(context.Background(), schemaVersionQuery).Scan(&version)
+	return version, err
+}
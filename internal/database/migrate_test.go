@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestConn(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestMigrateEmbedded(t *testing.T) {
+	sqliteMigrations, err := dialectMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatalf("dialectMigrations: %v", err)
+	}
+	postgresMigrations, err := dialectMigrations(postgresDialect)
+	if err != nil {
+		t.Fatalf("dialectMigrations: %v", err)
+	}
+
+	// У каждой СУБД должен быть одинаковый набор версий
+	sqliteList, err := loadMigrations(sqliteMigrations)
+	if err != nil {
+		t.Fatalf("loadMigrations(sqlite): %v", err)
+	}
+	postgresList, err := loadMigrations(postgresMigrations)
+	if err != nil {
+		t.Fatalf("loadMigrations(postgres): %v", err)
+	}
+	if len(sqliteList) != len(postgresList) {
+		t.Fatalf("sqlite has %d migrations, postgres has %d", len(sqliteList), len(postgresList))
+	}
+	for i := range sqliteList {
+		if sqliteList[i].Name != postgresList[i].Name {
+			t.Errorf("migration %d: sqlite %s, postgres %s", i, sqliteList[i].Name, postgresList[i].Name)
+		}
+	}
+
+	forEachBackend(t, func(t *testing.T, b backend) {
+		db := openTestDB(t, b)
+
+		version, err := db.SchemaVersion()
+		if err != nil {
+			t.Fatalf("SchemaVersion: %v", err)
+		}
+		if want := sqliteList[len(sqliteList)-1].Version; version != want {
+			t.Fatalf("version = %d, want %d", version, want)
+		}
+
+		// Повторный запуск ничего не применяет
+		migrations, _ := dialectMigrations(b.dialect)
+		if err := migrate(db.Conn, b.dialect, migrations); err != nil {
+			t.Fatalf("second migrate: %v", err)
+		}
+	})
+}
+
+func TestMigrateOrderAndRollback(t *testing.T) {
+	conn := openTestConn(t)
+
+	fsys := fstest.MapFS{
+		"0002_add_note.up.sql": {Data: []byte("ALTER TABLE items ADD COLUMN note TEXT;")},
+		"0001_items.up.sql":    {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);")},
+	}
+	if err := migrate(conn, sqliteDialect, fsys); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := conn.Exec("INSERT INTO items (id, note) VALUES (1, 'ok')"); err != nil {
+		t.Fatalf("insert after migrations: %v", err)
+	}
+
+	fsys["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE extra (id INTEGER); SELECT * FROM missing;")}
+	if err := migrate(conn, sqliteDialect, fsys); err == nil {
+		t.Fatal("broken migration was applied")
+	}
+
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'extra'").Scan(&count); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if count != 0 {
+		t.Fatal("broken migration was not rolled back")
+	}
+}
+
+func TestMigrateRefusesNewerDatabase(t *testing.T) {
+	conn := openTestConn(t)
+
+	fsys := fstest.MapFS{
+		"0001_items.up.sql": {Data: []byte("CREATE TABLE items (id INTEGER PRIMARY KEY);")},
+	}
+	if err := migrate(conn, sqliteDialect, fsys); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := conn.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES (7, CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	err := migrate(conn, sqliteDialect, fsys)
+	if err == nil || !strings.Contains(err.Error(), "новее") {
+		t.Fatalf("err = %v, want newer schema error", err)
+	}
+}
+
+func TestMigrateMovesConfigsToDevices(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		conn, err := sql.Open(b.dialect.driver, b.dsn(t))
+		if err != nil {
+			t.Fatalf("sql.Open: %v", err)
+		}
+		defer conn.Close()
+
+		migrations, err := dialectMigrations(b.dialect)
+		if err != nil {
+			t.Fatalf("dialectMigrations: %v", err)
+		}
+		data, err := fs.ReadFile(migrations, "0001_create_users.up.sql")
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		initial := fstest.MapFS{"0001_create_users.up.sql": {Data: data}}
+
+		if err := migrate(conn, b.dialect, initial); err != nil {
+			t.Fatalf("migrate v1: %v", err)
+		}
+		if _, err := conn.Exec("INSERT INTO users (id, config1, config2, config3) VALUES (10, 'ss://a', '', 'ss://c'), (20, '', '', '')"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+
+		if err := migrate(conn, b.dialect, migrations); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+
+		db := &DB{Conn: conn, dialect: b.dialect}
+		devices, err := db.GetUserDevices(context.Background(), 10)
+		if err != nil {
+			t.Fatalf("GetUserDevices: %v", err)
+		}
+		if len(devices) != 2 {
+			t.Fatalf("got %d devices, want 2", len(devices))
+		}
+		if devices[0].Slot != 1 || devices[0].Link != "ss://a" || devices[0].MarzbanUsername != "10_device1" {
+			t.Errorf("device 1 = %+v", devices[0])
+		}
+		if devices[1].Slot != 3 || devices[1].Link != "ss://c" || devices[1].MarzbanUsername != "10_device3" {
+			t.Errorf("device 3 = %+v", devices[1])
+		}
+
+		if devices, _ := db.GetUserDevices(context.Background(), 20); len(devices) != 0 {
+			t.Errorf("user without configs got %d devices", len(devices))
+		}
+	})
+}
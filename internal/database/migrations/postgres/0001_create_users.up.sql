@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS users (
+	id BIGINT PRIMARY KEY,
+	balance DOUBLE PRECISION DEFAULT 0,
+	is_trial BOOLEAN DEFAULT FALSE,
+	is_active BOOLEAN DEFAULT FALSE,
+	is_friend BOOLEAN DEFAULT FALSE,
+	subscription_end_date TIMESTAMPTZ DEFAULT NULL,
+	config1 TEXT DEFAULT '',
+	config2 TEXT DEFAULT '',
+	config3 TEXT DEFAULT '',
+	refferer_id BIGINT DEFAULT NULL
+);
@@ -0,0 +1,10 @@
+CREATE TABLE payments (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	amount DOUBLE PRECISION NOT NULL,
+	provider TEXT NOT NULL DEFAULT '',
+	external_id TEXT DEFAULT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_user_id ON payments (user_id);
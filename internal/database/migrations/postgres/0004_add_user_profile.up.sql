@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN username TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN first_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN last_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN language_code TEXT NOT NULL DEFAULT '';
+-- Время регистрации существующих пользователей неизвестно
+ALTER TABLE users ADD COLUMN created_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE users ADD COLUMN last_seen_at TIMESTAMPTZ DEFAULT NULL;
+ALTER TABLE users ADD COLUMN blocked_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_users_username ON users (LOWER(username));
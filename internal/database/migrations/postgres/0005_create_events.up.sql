@@ -0,0 +1,13 @@
+-- Журнал изменений состояния. user_id без внешнего ключа: записи
+-- должны переживать удаление пользователя.
+CREATE TABLE events (
+	id BIGSERIAL PRIMARY KEY,
+	type TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	user_id BIGINT DEFAULT NULL,
+	details TEXT NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_events_user_id ON events (user_id, created_at);
+CREATE INDEX idx_events_created_at ON events (created_at);
@@ -0,0 +1,3 @@
+-- Мягкое удаление: строка пользователя остаётся, чтобы сохранить связь
+-- с платежами и не выдавать повторный пробный период
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
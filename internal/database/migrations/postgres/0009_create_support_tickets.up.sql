@@ -0,0 +1,25 @@
+-- Обращения в поддержку. У пользователя не больше одного открытого обращения.
+CREATE TABLE support_tickets (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	status TEXT NOT NULL DEFAULT 'open',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	closed_at TIMESTAMPTZ DEFAULT NULL,
+	closed_by BIGINT DEFAULT NULL
+);
+
+CREATE UNIQUE INDEX idx_support_tickets_open ON support_tickets (user_id) WHERE status = 'open';
+
+-- Сообщения обращения в чате поддержки: по ответу агента на такое
+-- сообщение находим обращение и пользователя.
+CREATE TABLE support_messages (
+	id BIGSERIAL PRIMARY KEY,
+	ticket_id BIGINT NOT NULL REFERENCES support_tickets(id),
+	chat_id BIGINT NOT NULL,
+	message_id BIGINT NOT NULL,
+	from_agent BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (chat_id, message_id)
+);
+
+CREATE INDEX idx_support_messages_ticket_id ON support_messages (ticket_id);
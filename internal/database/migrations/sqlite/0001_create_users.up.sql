@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY,
+	balance REAL DEFAULT 0,
+	is_trial BOOLEAN DEFAULT FALSE,
+	is_active BOOLEAN DEFAULT FALSE,
+	is_friend BOOLEAN DEFAULT FALSE,
+	subscription_end_date DATETIME DEFAULT NULL,
+	config1 TEXT DEFAULT '',
+	config2 TEXT DEFAULT '',
+	config3 TEXT DEFAULT '',
+	refferer_id INTEGER DEFAULT NULL
+);
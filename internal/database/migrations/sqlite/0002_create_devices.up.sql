@@ -0,0 +1,30 @@
+CREATE TABLE devices (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	slot INTEGER NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	marzban_username TEXT NOT NULL UNIQUE,
+	location TEXT NOT NULL DEFAULT '',
+	protocol TEXT NOT NULL DEFAULT '',
+	link TEXT NOT NULL DEFAULT '',
+	subscription_url TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	status TEXT NOT NULL DEFAULT 'active',
+	UNIQUE (user_id, slot)
+);
+
+CREATE INDEX idx_devices_user_id ON devices (user_id);
+
+-- Переносим конфиги из фиксированных колонок users.config1..3
+INSERT INTO devices (user_id, slot, marzban_username, location, protocol, link)
+SELECT id, 1, id || '_device1', '🇵🇱 Польша', 'shadowsocks', config1 FROM users WHERE config1 <> '';
+
+INSERT INTO devices (user_id, slot, marzban_username, location, protocol, link)
+SELECT id, 2, id || '_device2', '🇵🇱 Польша', 'shadowsocks', config2 FROM users WHERE config2 <> '';
+
+INSERT INTO devices (user_id, slot, marzban_username, location, protocol, link)
+SELECT id, 3, id || '_device3', '🇵🇱 Польша', 'shadowsocks', config3 FROM users WHERE config3 <> '';
+
+ALTER TABLE users DROP COLUMN config1;
+ALTER TABLE users DROP COLUMN config2;
+ALTER TABLE users DROP COLUMN config3;
@@ -0,0 +1,10 @@
+CREATE TABLE payments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	amount REAL NOT NULL,
+	provider TEXT NOT NULL DEFAULT '',
+	external_id TEXT DEFAULT NULL UNIQUE,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payments_user_id ON payments (user_id);
@@ -0,0 +1,2 @@
+-- Индекс для выборки активных пользователей по дате окончания подписки
+CREATE INDEX idx_users_active_expiry ON users (is_active, subscription_end_date);
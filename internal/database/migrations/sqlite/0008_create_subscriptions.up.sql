@@ -0,0 +1,18 @@
+-- Периоды подписки: по одной строке на каждый выданный период.
+-- users.subscription_end_date равна максимальному ends_at пользователя.
+CREATE TABLE subscriptions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id),
+	source TEXT NOT NULL,
+	plan TEXT NOT NULL DEFAULT '',
+	starts_at DATETIME NOT NULL,
+	ends_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_subscriptions_user_id ON subscriptions (user_id, ends_at);
+
+-- Для существующих пользователей известна только дата окончания,
+-- поэтому переносим её периодом нулевой длины с источником legacy
+INSERT INTO subscriptions (user_id, source, starts_at, ends_at)
+SELECT id, 'legacy', subscription_end_date, subscription_end_date FROM users WHERE subscription_end_date IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Состояние диалога: бот ждёт от пользователя ввода (текст обращения,
+-- рассылка и т.п.). Внешнего ключа нет: администратор может не быть
+-- зарегистрированным пользователем бота.
+CREATE TABLE user_states (
+	user_id INTEGER PRIMARY KEY,
+	state TEXT NOT NULL,
+	data TEXT NOT NULL DEFAULT '{}',
+	expires_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_states_expires_at ON user_states (expires_at);
@@ -0,0 +1,3 @@
+-- Язык интерфейса, выбранный пользователем. language_code из профиля
+-- Telegram обновляется при каждом обращении, поэтому хранится отдельно.
+ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Payment — зачисление средств на баланс пользователя.
+type Payment struct {
+	ID         int64
+	UserID     int64
+	Amount     float64
+	Provider   string
+	ExternalID string // идентификатор платежа у провайдера, может быть пустым
+	CreatedAt  time.Time
+}
+
+const paymentColumns = "id, user_id, amount, provider, COALESCE(external_id, ''), created_at"
+
+// erasedPrefix отмечает идентификатор платежа, заменённый хешем при
+// удалении данных пользователя.
+const erasedPrefix = "erased:"
+
+// erasedExternalID возвращает то, что остаётся от идентификатора платежа у
+// провайдера после удаления данных пользователя: по хешу повторное
+// уведомление о том же платеже распознаётся, но сам идентификатор не
+// восстановить.
+func erasedExternalID(id string) string {
+	if strings.HasPrefix(id, erasedPrefix) {
+		return id
+	}
+	sum := sha256.Sum256([]byte(id))
+	return erasedPrefix + hex.EncodeToString(sum[:])
+}
+
+func (db *DB) CreatePayment(ctx context.Context, p *Payment) error {
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.CreatedAt = p.CreatedAt.UTC()
+
+	var externalID sql.NullString
+	if p.ExternalID != "" {
+		externalID = sql.NullString{String: p.ExternalID, Valid: true}
+	}
+
+	return db.WithTx(ctx, func(tx *Tx) error {
+		// Сначала баланс: так отсутствующий пользователь даёт ErrNotFound,
+		// а не нарушение внешнего ключа при вставке платежа
+		if err := tx.execAffecting(ctx, "UPDATE users SET balance = balance + ? WHERE id = ?", p.Amount, p.UserID); err != nil {
+			return err
+		}
+
+		if externalID.Valid {
+			// Платёж удалённого пользователя хранится под хешем идентификатора
+			var erased int
+			if err := tx.queryRow(ctx, "SELECT COUNT(*) FROM payments WHERE external_id = ?", erasedExternalID(externalID.String)).Scan(&erased); err != nil {
+				return err
+			}
+			if erased > 0 {
+				return ErrDuplicatePayment
+			}
+		}
+
+		query := "INSERT INTO payments (user_id, amount, provider, external_id, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id"
+		err := tx.queryRow(ctx, query, p.UserID, p.Amount, p.Provider, externalID, p.CreatedAt).Scan(&p.ID)
+		if err != nil {
+			if externalID.Valid && isUniqueViolation(err) {
+				return ErrDuplicatePayment
+			}
+			return err
+		}
+
+		return insertEvent(ctx, tx, paymentEvent(p))
+	})
+}
+
+func paymentEvent(p *Payment) *Event {
+	details := map[string]interface{}{"payment_id": p.ID, "amount": p.Amount}
+	if p.ExternalID != "" {
+		details["external_id"] = p.ExternalID
+	}
+	return &Event{
+		Type:    EventPaymentCredited,
+		Actor:   ActorProvider(p.Provider),
+		UserID:  p.UserID,
+		Details: details,
+	}
+}
+
+func (db *DB) GetUserPayments(ctx context.Context, userID int64) ([]Payment, error) {
+	rows, err := db.query(ctx, "SELECT "+paymentColumns+" FROM payments WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []Payment
+	for rows.Next() {
+		var p Payment
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Amount, &p.Provider, &p.ExternalID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+
+	return payments, rows.Err()
+}
+
+// isUniqueViolation распознаёт нарушение уникального индекса в SQLite и PostgreSQL.
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "duplicate key value violates unique constraint")
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound возвращается, если запрошенная запись не существует.
+var ErrNotFound = errors.New("запись не найдена")
+
+// ErrDuplicatePayment возвращается при повторной обработке платежа
+// с тем же внешним идентификатором.
+var ErrDuplicatePayment = errors.New("платёж уже обработан")
+
+// UserRepository хранит пользователей бота.
+type UserRepository interface {
+	GetUserByID(ctx context.Context, userID int64) (User, error)
+	GetAllUsers(ctx context.Context) ([]User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	// UsersExpiringBetween и ExpiredActiveUsers потоково передают в fn
+	// активных пользователей (кроме друзей) по дате окончания подписки.
+	UsersExpiringBetween(ctx context.Context, from, to time.Time, fn func(User) error) error
+	ExpiredActiveUsers(ctx context.Context, now time.Time, fn func(User) error) error
+	CountActiveUsers(ctx context.Context) (int, error)
+	CreateUser(ctx context.Context, userID int64, trialDays int) error
+	UpdateUserBalance(ctx context.Context, userID int64, amount float64) error
+	UpdateTrialStatus(ctx context.Context, userID int64, isTrial bool) error
+	UpdateActiveStatus(ctx context.Context, userID int64, isActive bool) error
+	UpdateSubscriptionEndDate(ctx context.Context, userID int64, endDate time.Time) error
+	UpdateReffererID(ctx context.Context, reffererID int64, userID int64) error
+	// TouchUser обновляет профиль Telegram и время последнего обращения.
+	TouchUser(ctx context.Context, p Profile) error
+	SetBlockedBot(ctx context.Context, userID int64, blocked bool) error
+	SetUserLanguage(ctx context.Context, userID int64, lang string) error
+
+	// DeactivateUser удаляет устройства пользователя и снимает флаги
+	// пробного периода и активности одной операцией.
+	DeactivateUser(ctx context.Context, userID int64) error
+	// ActivateSubscription продлевает подписку до until периодом из
+	// источника source и делает пользователя активным платным.
+	ActivateSubscription(ctx context.Context, userID int64, source string, until time.Time) error
+	// EraseUser очищает персональные данные и помечает пользователя удалённым.
+	// Повторный вызов возвращает ErrNotFound.
+	EraseUser(ctx context.Context, userID int64) error
+}
+
+// SubscriptionRepository хранит периоды подписки. Дата окончания подписки
+// пользователя всегда равна окончанию последнего периода.
+type SubscriptionRepository interface {
+	GrantSubscription(ctx context.Context, userID int64, source, plan string, days int) (Subscription, error)
+	GetUserSubscriptions(ctx context.Context, userID int64) ([]Subscription, error)
+}
+
+// SupportRepository хранит обращения в поддержку и связь сообщений в чате
+// поддержки с обращениями.
+type SupportRepository interface {
+	OpenTicket(ctx context.Context, userID int64) (ticket SupportTicket, created bool, err error)
+	GetOpenTicket(ctx context.Context, userID int64) (SupportTicket, error)
+	GetTicket(ctx context.Context, ticketID int64) (SupportTicket, error)
+	AddSupportMessage(ctx context.Context, ticketID, chatID int64, messageID int, fromAgent bool) error
+	GetTicketBySupportMessage(ctx context.Context, chatID int64, messageID int) (SupportTicket, error)
+	CloseTicket(ctx context.Context, ticketID, closedBy int64) error
+}
+
+// StateRepository хранит состояния диалогов с пользователями.
+type StateRepository interface {
+	SetUserState(ctx context.Context, s UserState) error
+	// GetUserState возвращает и истёкшее состояние; ErrNotFound, если его нет.
+	GetUserState(ctx context.Context, userID int64) (UserState, error)
+	ClearUserState(ctx context.Context, userID int64) error
+	DeleteExpiredStates(ctx context.Context, now time.Time) (int, error)
+}
+
+// DeviceRepository хранит устройства пользователей.
+type DeviceRepository interface {
+	GetUserDevices(ctx context.Context, userID int64) ([]Device, error)
+	GetDevice(ctx context.Context, userID int64, slot int) (Device, error)
+	GetDeviceByMarzbanUsername(ctx context.Context, username string) (Device, error)
+	FreeDeviceSlot(ctx context.Context, userID int64) (int, error)
+	CreateDevice(ctx context.Context, d *Device) error
+	// AddDevice сохраняет устройство, только если пользователь активен и
+	// лимит устройств не исчерпан; иначе ErrUserInactive или ErrDeviceLimit.
+	AddDevice(ctx context.Context, d *Device, limit int) error
+	UpdateDeviceLink(ctx context.Context, deviceID int64, link, subscriptionURL string) error
+	UpdateDeviceStatus(ctx context.Context, deviceID int64, status string) error
+	DeleteDevice(ctx context.Context, deviceID int64) error
+}
+
+// PaymentRepository хранит платежи и зачисляет их на баланс.
+type PaymentRepository interface {
+	// CreatePayment сохраняет платёж и пополняет баланс пользователя
+	// одной операцией. Если у платежа задан ExternalID, который уже
+	// встречался, возвращается ErrDuplicatePayment.
+	CreatePayment(ctx context.Context, p *Payment) error
+	GetUserPayments(ctx context.Context, userID int64) ([]Payment, error)
+}
+
+// EventRepository хранит журнал изменений состояния.
+type EventRepository interface {
+	RecordEvent(ctx context.Context, e *Event) error
+	ListEvents(ctx context.Context, f EventFilter) ([]Event, error)
+}
+
+// Store объединяет все репозитории; реализуется DB и MemoryStore.
+type Store interface {
+	UserRepository
+	SubscriptionRepository
+	SupportRepository
+	StateRepository
+	DeviceRepository
+	PaymentRepository
+	EventRepository
+	Close()
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if _, err := db.GetUserByID(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("GetUserByID on empty db: err = %v, want ErrNotFound", err)
+		}
+		if err := db.UpdateUserBalance(ctx, 1, 10); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateUserBalance of missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID after CreateUser: %v", err)
+		}
+		if !user.IsTrial || !user.IsActive || user.IsFriend {
+			t.Errorf("new user flags = %+v", user)
+		}
+		if days := time.Until(user.SubscriptionEndDate.Time).Hours() / 24; days < 6 || days > 7 {
+			t.Errorf("trial ends in %.1f days, want 7", days)
+		}
+
+		if err := db.UpdateUserBalance(ctx, 1, 150.5); err != nil {
+			t.Fatalf("UpdateUserBalance: %v", err)
+		}
+		if err := db.UpdateTrialStatus(ctx, 1, false); err != nil {
+			t.Fatalf("UpdateTrialStatus: %v", err)
+		}
+		if err := db.UpdateActiveStatus(ctx, 1, false); err != nil {
+			t.Fatalf("UpdateActiveStatus: %v", err)
+		}
+		if err := db.UpdateReffererID(ctx, 42, 1); err != nil {
+			t.Fatalf("UpdateReffererID: %v", err)
+		}
+		end := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := db.UpdateSubscriptionEndDate(ctx, 1, end); err != nil {
+			t.Fatalf("UpdateSubscriptionEndDate: %v", err)
+		}
+
+		user, err = db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.Balance != 150.5 || user.IsTrial || user.IsActive || user.ReffererId != 42 {
+			t.Errorf("updated user = %+v", user)
+		}
+		if !user.SubscriptionEndDate.Time.Equal(end) {
+			t.Errorf("subscription end = %v, want %v", user.SubscriptionEndDate.Time, end)
+		}
+
+		if err := db.CreateUser(ctx, 2, 3); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		users, err := db.GetAllUsers(ctx)
+		if err != nil {
+			t.Fatalf("GetAllUsers: %v", err)
+		}
+		if len(users) != 2 {
+			t.Errorf("GetAllUsers returned %d users, want 2", len(users))
+		}
+	})
+}
+
+func TestDevices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		first := &Device{UserID: 1, Slot: 1, MarzbanUsername: "1_device1", Link: "ss://one"}
+		if err := db.CreateDevice(ctx, first); err != nil {
+			t.Fatalf("CreateDevice: %v", err)
+		}
+		if first.ID == 0 {
+			t.Fatal("CreateDevice did not set ID")
+		}
+		if first.CreatedAt.Location() != time.UTC {
+			t.Errorf("CreatedAt = %v, want UTC", first.CreatedAt)
+		}
+		second := &Device{UserID: 1, Slot: 3, MarzbanUsername: "1_device3", Link: "ss://three"}
+		if err := db.CreateDevice(ctx, second); err != nil {
+			t.Fatalf("CreateDevice: %v", err)
+		}
+		if err := db.CreateDevice(ctx, &Device{UserID: 1, Slot: 1, MarzbanUsername: "1_device1b"}); err == nil {
+			t.Error("CreateDevice allowed a duplicate slot")
+		}
+
+		slot, err := db.FreeDeviceSlot(ctx, 1)
+		if err != nil || slot != 2 {
+			t.Errorf("FreeDeviceSlot = %d, %v; want 2", slot, err)
+		}
+
+		if err := db.UpdateDeviceLink(ctx, first.ID, "ss://new", "https://sub"); err != nil {
+			t.Fatalf("UpdateDeviceLink: %v", err)
+		}
+		if err := db.UpdateDeviceStatus(ctx, first.ID, DeviceStatusDisabled); err != nil {
+			t.Fatalf("UpdateDeviceStatus: %v", err)
+		}
+
+		got, err := db.GetDevice(ctx, 1, 1)
+		if err != nil {
+			t.Fatalf("GetDevice: %v", err)
+		}
+		if got.Link != "ss://new" || got.SubscriptionURL != "https://sub" || got.Status != DeviceStatusDisabled {
+			t.Errorf("device after update = %+v", got)
+		}
+
+		byName, err := db.GetDeviceByMarzbanUsername(ctx, "1_device3")
+		if err != nil || byName.ID != second.ID {
+			t.Errorf("GetDeviceByMarzbanUsername = %+v, %v", byName, err)
+		}
+
+		if err := db.DeleteDevice(ctx, first.ID); err != nil {
+			t.Fatalf("DeleteDevice: %v", err)
+		}
+		if _, err := db.GetDevice(ctx, 1, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetDevice after delete: err = %v, want ErrNotFound", err)
+		}
+		if err := db.DeleteDevice(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second DeleteDevice: err = %v, want ErrNotFound", err)
+		}
+
+		devices, err := db.GetUserDevices(ctx, 1)
+		if err != nil || len(devices) != 1 || devices[0].Slot != 3 {
+			t.Errorf("GetUserDevices = %+v, %v", devices, err)
+		}
+	})
+}
+
+func TestPayments(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if err := db.CreatePayment(ctx, &Payment{UserID: 1, Amount: 100}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CreatePayment for missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		p := &Payment{UserID: 1, Amount: 100, Provider: "test", ExternalID: "pay-1"}
+		if err := db.CreatePayment(ctx, p); err != nil {
+			t.Fatalf("CreatePayment: %v", err)
+		}
+		if p.ID == 0 {
+			t.Error("CreatePayment did not set ID")
+		}
+		if p.CreatedAt.Location() != time.UTC {
+			t.Errorf("CreatedAt = %v, want UTC", p.CreatedAt)
+		}
+		if err := db.CreatePayment(ctx, &Payment{UserID: 1, Amount: 100, ExternalID: "pay-1"}); !errors.Is(err, ErrDuplicatePayment) {
+			t.Errorf("repeated CreatePayment: err = %v, want ErrDuplicatePayment", err)
+		}
+		// Платежи без внешнего идентификатора не считаются повторами
+		for i := 0; i < 2; i++ {
+			if err := db.CreatePayment(ctx, &Payment{UserID: 1, Amount: 25}); err != nil {
+				t.Fatalf("CreatePayment without external id: %v", err)
+			}
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.Balance != 150 {
+			t.Errorf("balance = %v, want 150", user.Balance)
+		}
+
+		payments, err := db.GetUserPayments(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserPayments: %v", err)
+		}
+		if len(payments) != 3 || payments[0].ExternalID != "pay-1" || payments[0].Provider != "test" {
+			t.Errorf("GetUserPayments = %+v", payments)
+		}
+	})
+}
+
+func TestUserProfile(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		profile := Profile{UserID: 1, Username: "Alice", FirstName: "Алиса", LastName: "Иванова", LanguageCode: "ru"}
+		if err := db.TouchUser(ctx, profile); !errors.Is(err, ErrNotFound) {
+			t.Errorf("TouchUser of missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if !user.CreatedAt.Valid || time.Since(user.CreatedAt.Time) > time.Minute {
+			t.Errorf("created_at = %v, want now", user.CreatedAt)
+		}
+
+		if err := db.SetBlockedBot(ctx, 1, true); err != nil {
+			t.Fatalf("SetBlockedBot: %v", err)
+		}
+		if err := db.TouchUser(ctx, profile); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+
+		user, err = db.GetUserByUsername(ctx, "@alice")
+		if err != nil {
+			t.Fatalf("GetUserByUsername: %v", err)
+		}
+		if user.ID != 1 || user.FirstName != "Алиса" || user.LastName != "Иванова" || user.LanguageCode != "ru" {
+			t.Errorf("user after TouchUser = %+v", user)
+		}
+		if user.BlockedBot {
+			t.Error("TouchUser did not reset blocked_bot")
+		}
+		if !user.LastSeenAt.Valid {
+			t.Error("last_seen_at not set")
+		}
+
+		if _, err := db.GetUserByUsername(ctx, ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetUserByUsername(\"\"): err = %v, want ErrNotFound", err)
+		}
+
+		// Выбранный язык не меняется вместе с language_code из Telegram
+		if err := db.SetUserLanguage(ctx, 1, "en"); err != nil {
+			t.Fatalf("SetUserLanguage: %v", err)
+		}
+		if err := db.TouchUser(ctx, profile); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+		if user, _ := db.GetUserByID(ctx, 1); user.Language != "en" || user.LanguageCode != "ru" {
+			t.Errorf("language = %q, language_code = %q, want en and ru", user.Language, user.LanguageCode)
+		}
+		if err := db.SetUserLanguage(ctx, 2, "en"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("SetUserLanguage of missing user: err = %v, want ErrNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UserState — состояние диалога: бот ждёт от пользователя ввода и знает,
+// какой обработчик его получит. Data хранит значения предыдущих шагов.
+type UserState struct {
+	UserID    int64
+	State     string
+	Data      map[string]string
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+// Expired сообщает, что время ожидания ввода истекло.
+func (s UserState) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// SetUserState сохраняет состояние диалога, заменяя предыдущее.
+func (db *DB) SetUserState(ctx context.Context, s UserState) error {
+	data := []byte("{}")
+	if len(s.Data) > 0 {
+		var err error
+		if data, err = json.Marshal(s.Data); err != nil {
+			return fmt.Errorf("ошибка сериализации состояния %s: %w", s.State, err)
+		}
+	}
+
+	query := "INSERT INTO user_states (user_id, state, data, expires_at, updated_at) VALUES (?, ?, ?, ?, ?) " +
+		"ON CONFLICT (user_id) DO UPDATE SET state = excluded.state, data = excluded.data, " +
+		"expires_at = excluded.expires_at, updated_at = excluded.updated_at"
+	_, err := db.exec(ctx, query, s.UserID, s.State, string(data), s.ExpiresAt.UTC(), time.Now().UTC())
+	return err
+}
+
+// GetUserState возвращает состояние диалога, в том числе истёкшее, чтобы
+// бот мог сообщить пользователю о таймауте. Если состояния нет — ErrNotFound.
+func (db *DB) GetUserState(ctx context.Context, userID int64) (UserState, error) {
+	s := UserState{UserID: userID}
+	var data string
+	query := "SELECT state, data, expires_at, updated_at FROM user_states WHERE user_id = ?"
+	err := db.queryRow(ctx, query, userID).Scan(&s.State, &data, &s.ExpiresAt, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserState{}, ErrNotFound
+	}
+	if err != nil {
+		return UserState{}, err
+	}
+	if err := json.Unmarshal([]byte(data), &s.Data); err != nil {
+		return UserState{}, fmt.Errorf("ошибка чтения состояния пользователя %d: %w", userID, err)
+	}
+	return s, nil
+}
+
+func (db *DB) ClearUserState(ctx context.Context, userID int64) error {
+	_, err := db.exec(ctx, "DELETE FROM user_states WHERE user_id = ?", userID)
+	return err
+}
+
+// DeleteExpiredStates удаляет состояния, истёкшие к now, и возвращает их число.
+func (db *DB) DeleteExpiredStates(ctx context.Context, now time.Time) (int, error) {
+	res, err := db.exec(ctx, "DELETE FROM user_states WHERE expires_at <= ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
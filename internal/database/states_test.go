@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserStates(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+		now := time.Now()
+
+		if _, err := db.GetUserState(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetUserState without state: err = %v, want ErrNotFound", err)
+		}
+
+		// Пользователь может быть не зарегистрирован, например администратор
+		state := UserState{UserID: 1, State: "broadcast", Data: map[string]string{"message_id": "42"}, ExpiresAt: now.Add(time.Hour)}
+		if err := db.SetUserState(ctx, state); err != nil {
+			t.Fatalf("SetUserState: %v", err)
+		}
+		got, err := db.GetUserState(ctx, 1)
+		if err != nil || got.State != "broadcast" || got.Data["message_id"] != "42" || got.Expired(now) {
+			t.Errorf("GetUserState = %+v, %v", got, err)
+		}
+
+		// Новое состояние заменяет предыдущее вместе с данными
+		state = UserState{UserID: 1, State: "support", ExpiresAt: now.Add(-time.Minute)}
+		if err := db.SetUserState(ctx, state); err != nil {
+			t.Fatalf("SetUserState: %v", err)
+		}
+		got, err = db.GetUserState(ctx, 1)
+		if err != nil || got.State != "support" || len(got.Data) != 0 || !got.Expired(now) {
+			t.Errorf("replaced state = %+v, %v", got, err)
+		}
+
+		if err := db.SetUserState(ctx, UserState{UserID: 2, State: "support", ExpiresAt: now.Add(time.Hour)}); err != nil {
+			t.Fatalf("SetUserState: %v", err)
+		}
+		if n, err := db.DeleteExpiredStates(ctx, now); err != nil || n != 1 {
+			t.Errorf("DeleteExpiredStates = %d, %v, want 1", n, err)
+		}
+		if _, err := db.GetUserState(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expired state was not deleted: err = %v", err)
+		}
+
+		if err := db.ClearUserState(ctx, 2); err != nil {
+			t.Fatalf("ClearUserState: %v", err)
+		}
+		if _, err := db.GetUserState(ctx, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetUserState after clear: err = %v, want ErrNotFound", err)
+		}
+	})
+}
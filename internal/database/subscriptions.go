@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Источники периодов подписки.
+const (
+	SourceTrial    = "trial"
+	SourcePayment  = "payment"
+	SourcePromo    = "promo"
+	SourceReferral = "referral"
+	SourceAdmin    = "admin"
+	SourceGift     = "gift"
+	// SourceLegacy — период, перенесённый из subscription_end_date при
+	// миграции; его начало неизвестно, поэтому длина нулевая
+	SourceLegacy = "legacy"
+)
+
+// Subscription — один выданный пользователю период подписки.
+type Subscription struct {
+	ID        int64
+	UserID    int64
+	Source    string
+	Plan      string
+	StartsAt  time.Time
+	EndsAt    time.Time
+	CreatedAt time.Time
+}
+
+// Days возвращает длину периода в днях.
+func (s Subscription) Days() float64 {
+	return s.EndsAt.Sub(s.StartsAt).Hours() / 24
+}
+
+// TotalDays суммирует длину периодов из указанных источников
+// (всех, если источники не заданы).
+func TotalDays(periods []Subscription, sources ...string) float64 {
+	var total float64
+	for _, p := range periods {
+		if len(sources) > 0 && !containsString(sources, p.Source) {
+			continue
+		}
+		total += p.Days()
+	}
+	return total
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// periodStart возвращает начало нового периода: новый период
+// продолжает текущую подписку, если она ещё не закончилась.
+func periodStart(now time.Time, currentEnd sql.NullTime) time.Time {
+	if currentEnd.Valid && currentEnd.Time.After(now) {
+		return currentEnd.Time
+	}
+	return now
+}
+
+const subscriptionColumns = "id, user_id, source, plan, starts_at, ends_at, created_at"
+
+// GrantSubscription выдаёт пользователю период на days дней. Период
+// начинается с окончания текущей подписки или с текущего момента, если
+// подписка уже закончилась. Пользователь становится активным; флаг пробного
+// периода соответствует источнику.
+func (db *DB) GrantSubscription(ctx context.Context, userID int64, source, plan string, days int) (Subscription, error) {
+	var sub Subscription
+	err := db.WithTx(ctx, func(tx *Tx) error {
+		var currentEnd sql.NullTime
+		err := tx.queryRow(ctx, "SELECT subscription_end_date FROM users WHERE id = ?", userID).Scan(&currentEnd)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		start := periodStart(time.Now().UTC(), currentEnd)
+		sub = Subscription{UserID: userID, Source: source, Plan: plan, StartsAt: start, EndsAt: start.AddDate(0, 0, days)}
+		if err := insertSubscription(ctx, tx, &sub); err != nil {
+			return err
+		}
+		if err := syncSubscriptionEnd(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := "UPDATE users SET is_active = TRUE, is_trial = ? WHERE id = ?"
+		if err := tx.execAffecting(ctx, query, source == SourceTrial, userID); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, &Event{
+			Type:   EventSubscriptionExtended,
+			Actor:  ActorSystem,
+			UserID: userID,
+			Details: map[string]interface{}{
+				"source": source,
+				"plan":   plan,
+				"days":   days,
+				"until":  sub.EndsAt.Format(time.RFC3339),
+			},
+		})
+	})
+	return sub, err
+}
+
+// GetUserSubscriptions возвращает периоды подписки пользователя по порядку.
+func (db *DB) GetUserSubscriptions(ctx context.Context, userID int64) ([]Subscription, error) {
+	rows, err := db.query(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE user_id = ? ORDER BY starts_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Source, &s.Plan, &s.StartsAt, &s.EndsAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+
+	return subs, rows.Err()
+}
+
+func insertSubscription(ctx context.Context, q rowQuerier, s *Subscription) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	s.StartsAt = s.StartsAt.UTC()
+	s.EndsAt = s.EndsAt.UTC()
+	s.CreatedAt = s.CreatedAt.UTC()
+
+	query := "INSERT INTO subscriptions (user_id, source, plan, starts_at, ends_at, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id"
+	return q.queryRow(ctx, query, s.UserID, s.Source, s.Plan, s.StartsAt, s.EndsAt, s.CreatedAt).Scan(&s.ID)
+}
+
+// syncSubscriptionEnd пересчитывает users.subscription_end_date по периодам.
+func syncSubscriptionEnd(ctx context.Context, tx *Tx, userID int64) error {
+	query := "UPDATE users SET subscription_end_date = (SELECT MAX(ends_at) FROM subscriptions WHERE user_id = ?) WHERE id = ?"
+	return tx.execAffecting(ctx, query, userID, userID)
+}
+
+// setSubscriptionEnd переносит окончание подписки пользователя на end:
+// периоды, заходящие за end, обрезаются (история остаётся, их длина
+// уменьшается), а последний период продлевается до end. Если периодов нет,
+// создаётся период администратора.
+func setSubscriptionEnd(ctx context.Context, tx *Tx, userID int64, end time.Time) error {
+	end = end.UTC()
+	query := "UPDATE subscriptions SET starts_at = CASE WHEN starts_at > ? THEN ? ELSE starts_at END, ends_at = CASE WHEN ends_at > ? THEN ? ELSE ends_at END WHERE user_id = ?"
+	if _, err := tx.exec(ctx, query, end, end, end, end, userID); err != nil {
+		return err
+	}
+
+	var id int64
+	err := tx.queryRow(ctx, "SELECT id FROM subscriptions WHERE user_id = ? ORDER BY ends_at DESC, id DESC LIMIT 1", userID).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		start := time.Now().UTC()
+		if end.Before(start) {
+			start = end
+		}
+		sub := Subscription{UserID: userID, Source: SourceAdmin, StartsAt: start, EndsAt: end}
+		if err := insertSubscription(ctx, tx, &sub); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if _, err := tx.exec(ctx, "UPDATE subscriptions SET ends_at = ? WHERE id = ?", end, id); err != nil {
+			return err
+		}
+	}
+	return syncSubscriptionEnd(ctx, tx, userID)
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscriptions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if _, err := db.GrantSubscription(ctx, 1, SourcePayment, "month", 30); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GrantSubscription for missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		subs, err := db.GetUserSubscriptions(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserSubscriptions: %v", err)
+		}
+		if len(subs) != 1 || subs[0].Source != SourceTrial || subs[0].Days() < 6.99 || subs[0].Days() > 7.01 {
+			t.Fatalf("subscriptions after CreateUser = %+v, want one 7-day trial", subs)
+		}
+		trial := subs[0]
+
+		// Оплаченный период продолжает пробный
+		paid, err := db.GrantSubscription(ctx, 1, SourcePayment, "month", 30)
+		if err != nil {
+			t.Fatalf("GrantSubscription: %v", err)
+		}
+		if !paid.StartsAt.Equal(trial.EndsAt) || !paid.EndsAt.Equal(trial.EndsAt.AddDate(0, 0, 30)) {
+			t.Errorf("paid period = %v..%v, want to start at %v", paid.StartsAt, paid.EndsAt, trial.EndsAt)
+		}
+		if _, err := db.GrantSubscription(ctx, 1, SourcePromo, "", 10); err != nil {
+			t.Fatalf("GrantSubscription: %v", err)
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		wantEnd := paid.EndsAt.AddDate(0, 0, 10)
+		if !user.SubscriptionEndDate.Time.Equal(wantEnd) || !user.IsActive || user.IsTrial {
+			t.Errorf("user = %+v, want active paid user until %v", user, wantEnd)
+		}
+
+		subs, err = db.GetUserSubscriptions(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserSubscriptions: %v", err)
+		}
+		if len(subs) != 3 {
+			t.Fatalf("subscriptions = %+v, want 3", subs)
+		}
+		if got := TotalDays(subs, SourcePayment); got != 30 {
+			t.Errorf("paid days = %v, want 30", got)
+		}
+		if got := TotalDays(subs, SourcePayment, SourcePromo); got != 40 {
+			t.Errorf("paid and promo days = %v, want 40", got)
+		}
+
+		// Ручной перенос даты обрезает периоды, но сохраняет их в истории
+		past := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		if err := db.UpdateSubscriptionEndDate(ctx, 1, past); err != nil {
+			t.Fatalf("UpdateSubscriptionEndDate: %v", err)
+		}
+		if user, _ := db.GetUserByID(ctx, 1); !user.SubscriptionEndDate.Time.Equal(past) {
+			t.Errorf("end date after UpdateSubscriptionEndDate = %v, want %v", user.SubscriptionEndDate.Time, past)
+		}
+		subs, _ = db.GetUserSubscriptions(ctx, 1)
+		if len(subs) != 3 {
+			t.Errorf("subscriptions after UpdateSubscriptionEndDate = %+v, want 3", subs)
+		}
+		for _, s := range subs {
+			if s.EndsAt.Before(s.StartsAt) {
+				t.Errorf("period %+v ends before it starts", s)
+			}
+		}
+
+		// Истёкшая подписка продлевается с текущего момента
+		if err := db.CreateUser(ctx, 2, 0); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := db.UpdateSubscriptionEndDate(ctx, 2, past); err != nil {
+			t.Fatalf("UpdateSubscriptionEndDate: %v", err)
+		}
+		before := time.Now()
+		sub, err := db.GrantSubscription(ctx, 2, SourceGift, "", 3)
+		if err != nil {
+			t.Fatalf("GrantSubscription: %v", err)
+		}
+		if sub.StartsAt.Before(before.Add(-time.Second)) {
+			t.Errorf("period for expired user starts at %v, want now", sub.StartsAt)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Статусы обращения в поддержку.
+const (
+	TicketStatusOpen   = "open"
+	TicketStatusClosed = "closed"
+)
+
+// ErrTicketClosed возвращается CloseTicket для уже закрытого обращения.
+var ErrTicketClosed = errors.New("обращение уже закрыто")
+
+// SupportTicket — обращение пользователя в поддержку.
+type SupportTicket struct {
+	ID        int64
+	UserID    int64
+	Status    string
+	CreatedAt time.Time
+	ClosedAt  sql.NullTime
+	ClosedBy  int64 // ID агента в Telegram, 0 для открытых обращений
+}
+
+const ticketColumns = "id, user_id, status, created_at, closed_at, COALESCE(closed_by, 0)"
+
+func scanTicket(row interface{ Scan(...interface{}) error }) (SupportTicket, error) {
+	var t SupportTicket
+	err := row.Scan(&t.ID, &t.UserID, &t.Status, &t.CreatedAt, &t.ClosedAt, &t.ClosedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SupportTicket{}, ErrNotFound
+	}
+	return t, err
+}
+
+// OpenTicket возвращает открытое обращение пользователя или создаёт новое.
+// created сообщает, что обращение только что создано.
+func (db *DB) OpenTicket(ctx context.Context, userID int64) (ticket SupportTicket, created bool, err error) {
+	err = db.WithTx(ctx, func(tx *Tx) error {
+		query := "SELECT " + ticketColumns + " FROM support_tickets WHERE user_id = ? AND status = ?"
+		ticket, err = scanTicket(tx.queryRow(ctx, query, userID, TicketStatusOpen))
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := tx.userExists(ctx, userID); err != nil {
+			return err
+		}
+
+		ticket = SupportTicket{UserID: userID, Status: TicketStatusOpen, CreatedAt: time.Now().UTC()}
+		query = "INSERT INTO support_tickets (user_id, status, created_at) VALUES (?, ?, ?) RETURNING id"
+		if err := tx.queryRow(ctx, query, userID, ticket.Status, ticket.CreatedAt).Scan(&ticket.ID); err != nil {
+			return err
+		}
+		created = true
+		return insertEvent(ctx, tx, &Event{
+			Type:    EventTicketOpened,
+			Actor:   ActorUser(userID),
+			UserID:  userID,
+			Details: map[string]interface{}{"ticket_id": ticket.ID},
+		})
+	})
+	return ticket, created, err
+}
+
+// GetOpenTicket возвращает открытое обращение пользователя или ErrNotFound.
+func (db *DB) GetOpenTicket(ctx context.Context, userID int64) (SupportTicket, error) {
+	query := "SELECT " + ticketColumns + " FROM support_tickets WHERE user_id = ? AND status = ?"
+	return scanTicket(db.queryRow(ctx, query, userID, TicketStatusOpen))
+}
+
+func (db *DB) GetTicket(ctx context.Context, ticketID int64) (SupportTicket, error) {
+	return scanTicket(db.queryRow(ctx, "SELECT "+ticketColumns+" FROM support_tickets WHERE id = ?", ticketID))
+}
+
+// AddSupportMessage запоминает сообщение обращения в чате поддержки.
+func (db *DB) AddSupportMessage(ctx context.Context, ticketID, chatID int64, messageID int, fromAgent bool) error {
+	query := "INSERT INTO support_messages (ticket_id, chat_id, message_id, from_agent, created_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := db.exec(ctx, query, ticketID, chatID, messageID, fromAgent, time.Now().UTC())
+	return err
+}
+
+// GetTicketBySupportMessage находит обращение по сообщению в чате поддержки.
+func (db *DB) GetTicketBySupportMessage(ctx context.Context, chatID int64, messageID int) (SupportTicket, error) {
+	query := "SELECT " + ticketColumns + " FROM support_tickets " +
+		"WHERE id = (SELECT ticket_id FROM support_messages WHERE chat_id = ? AND message_id = ?)"
+	return scanTicket(db.queryRow(ctx, query, chatID, messageID))
+}
+
+// CloseTicket закрывает обращение от имени агента closedBy.
+func (db *DB) CloseTicket(ctx context.Context, ticketID, closedBy int64) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		ticket, err := scanTicket(tx.queryRow(ctx, "SELECT "+ticketColumns+" FROM support_tickets WHERE id = ?", ticketID))
+		if err != nil {
+			return err
+		}
+		if ticket.Status == TicketStatusClosed {
+			return ErrTicketClosed
+		}
+
+		query := "UPDATE support_tickets SET status = ?, closed_at = ?, closed_by = ? WHERE id = ?"
+		if _, err := tx.exec(ctx, query, TicketStatusClosed, time.Now().UTC(), closedBy, ticketID); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, &Event{
+			Type:    EventTicketClosed,
+			Actor:   ActorAdmin(closedBy),
+			UserID:  ticket.UserID,
+			Details: map[string]interface{}{"ticket_id": ticketID},
+		})
+	})
+}
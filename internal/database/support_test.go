@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSupportTickets(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if _, _, err := db.OpenTicket(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("OpenTicket for missing user: err = %v, want ErrNotFound", err)
+		}
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+
+		ticket, created, err := db.OpenTicket(ctx, 1)
+		if err != nil || !created || ticket.Status != TicketStatusOpen {
+			t.Fatalf("OpenTicket = %+v, %t, %v", ticket, created, err)
+		}
+		again, created, err := db.OpenTicket(ctx, 1)
+		if err != nil || created || again.ID != ticket.ID {
+			t.Errorf("second OpenTicket = %+v, %t, %v, want existing ticket %d", again, created, err, ticket.ID)
+		}
+
+		const supportChat = -100123
+		if err := db.AddSupportMessage(ctx, ticket.ID, supportChat, 42, false); err != nil {
+			t.Fatalf("AddSupportMessage: %v", err)
+		}
+		found, err := db.GetTicketBySupportMessage(ctx, supportChat, 42)
+		if err != nil || found.ID != ticket.ID || found.UserID != 1 {
+			t.Errorf("GetTicketBySupportMessage = %+v, %v", found, err)
+		}
+		if _, err := db.GetTicketBySupportMessage(ctx, supportChat, 43); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetTicketBySupportMessage of unknown message: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CloseTicket(ctx, ticket.ID, 777); err != nil {
+			t.Fatalf("CloseTicket: %v", err)
+		}
+		if err := db.CloseTicket(ctx, ticket.ID, 777); !errors.Is(err, ErrTicketClosed) {
+			t.Errorf("second CloseTicket: err = %v, want ErrTicketClosed", err)
+		}
+		closed, err := db.GetTicket(ctx, ticket.ID)
+		if err != nil || closed.Status != TicketStatusClosed || closed.ClosedBy != 777 || !closed.ClosedAt.Valid {
+			t.Errorf("closed ticket = %+v, %v", closed, err)
+		}
+		if _, err := db.GetOpenTicket(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetOpenTicket after close: err = %v, want ErrNotFound", err)
+		}
+
+		// После закрытия пользователь может открыть новое обращение
+		next, created, err := db.OpenTicket(ctx, 1)
+		if err != nil || !created || next.ID == ticket.ID {
+			t.Errorf("OpenTicket after close = %+v, %t, %v", next, created, err)
+		}
+	})
+}
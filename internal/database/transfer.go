@@ -0,0 +1,452 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// transferFormat — версия формата выгрузки Export. Меняется, только если
+// старый Import не сможет правильно прочитать новые записи.
+const transferFormat = 1
+
+// Типы записей выгрузки.
+const (
+	transferMeta         = "meta"
+	transferUser         = "user"
+	transferDevice       = "device"
+	transferPayment      = "payment"
+	transferSubscription = "subscription"
+)
+
+// TransferStats — число перенесённых записей каждого типа.
+type TransferStats struct {
+	Users         int
+	Devices       int
+	Payments      int
+	Subscriptions int
+	Referrals     int
+}
+
+func (s TransferStats) String() string {
+	return fmt.Sprintf("пользователей: %d, устройств: %d, платежей: %d, периодов подписки: %d, рефералов: %d",
+		s.Users, s.Devices, s.Payments, s.Subscriptions, s.Referrals)
+}
+
+// transferRecord — одна строка выгрузки в формате JSON Lines. Первая строка
+// всегда meta, заполнено ровно одно из остальных полей в соответствии с Type.
+type transferRecord struct {
+	Type         string                      `json:"type"`
+	Meta         *transferMetaRecord         `json:"meta,omitempty"`
+	User         *transferUserRecord         `json:"user,omitempty"`
+	Device       *transferDeviceRecord       `json:"device,omitempty"`
+	Payment      *transferPaymentRecord      `json:"payment,omitempty"`
+	Subscription *transferSubscriptionRecord `json:"subscription,omitempty"`
+}
+
+type transferMetaRecord struct {
+	Format        int       `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	Driver        string    `json:"driver"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+type transferUserRecord struct {
+	UserDataProfile
+	BlockedBot bool       `json:"blocked_bot,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+}
+
+type transferDeviceRecord struct {
+	UserID int64 `json:"user_id"`
+	UserDataDevice
+}
+
+type transferPaymentRecord struct {
+	UserID int64 `json:"user_id"`
+	UserDataPayment
+}
+
+type transferSubscriptionRecord struct {
+	UserID int64 `json:"user_id"`
+	UserDataSubscription
+}
+
+// Export записывает в w всех пользователей с их устройствами, платежами и
+// периодами подписки. Реферальные связи переносятся полем referrer_id
+// пользователя. Журнал событий не выгружается.
+func (db *DB) Export(ctx context.Context, w io.Writer) (TransferStats, error) {
+	var stats TransferStats
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	// Все таблицы читаются в одной транзакции, чтобы выгрузка была
+	// согласованной, даже если бот в это время работает с базой
+	err := db.withReadTx(ctx, func(tx *Tx) error {
+		return exportTx(ctx, tx, db.dialect.name, enc, &stats)
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, bw.Flush()
+}
+
+// exportTx записывает выгрузку, читая таблицы в транзакции tx.
+func exportTx(ctx context.Context, tx *Tx, driver string, enc *json.Encoder, stats *TransferStats) error {
+	var version int
+	err := tx.queryRow(ctx, schemaVersionQuery).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+
+	err = enc.Encode(transferRecord{Type: transferMeta, Meta: &transferMetaRecord{
+		Format:        transferFormat,
+		SchemaVersion: version,
+		Driver:        driver,
+		ExportedAt:    time.Now().UTC(),
+	}})
+	if err != nil {
+		return err
+	}
+
+	users, err := tx.query(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer users.Close()
+	for users.Next() {
+		u, err := scanUser(users)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(transferRecord{Type: transferUser, User: transferUserFrom(u)}); err != nil {
+			return err
+		}
+		stats.Users++
+		if u.ReffererId != 0 {
+			stats.Referrals++
+		}
+	}
+	if err := users.Err(); err != nil {
+		return err
+	}
+
+	devices, err := tx.query(ctx, "SELECT "+deviceColumns+" FROM devices ORDER BY user_id, slot")
+	if err != nil {
+		return err
+	}
+	defer devices.Close()
+	for devices.Next() {
+		d, err := scanDevice(devices)
+		if err != nil {
+			return err
+		}
+		rec := &transferDeviceRecord{UserID: d.UserID, UserDataDevice: UserDataDevice{
+			Slot:            d.Slot,
+			Name:            d.Name,
+			MarzbanUsername: d.MarzbanUsername,
+			Location:        d.Location,
+			Protocol:        d.Protocol,
+			Link:            d.Link,
+			SubscriptionURL: d.SubscriptionURL,
+			Status:          d.Status,
+			CreatedAt:       d.CreatedAt,
+		}}
+		if err := enc.Encode(transferRecord{Type: transferDevice, Device: rec}); err != nil {
+			return err
+		}
+		stats.Devices++
+	}
+	if err := devices.Err(); err != nil {
+		return err
+	}
+
+	payments, err := tx.query(ctx, "SELECT "+paymentColumns+" FROM payments ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer payments.Close()
+	for payments.Next() {
+		var p Payment
+		if err := payments.Scan(&p.ID, &p.UserID, &p.Amount, &p.Provider, &p.ExternalID, &p.CreatedAt); err != nil {
+			return err
+		}
+		rec := &transferPaymentRecord{UserID: p.UserID, UserDataPayment: UserDataPayment{
+			Amount:     p.Amount,
+			Provider:   p.Provider,
+			ExternalID: p.ExternalID,
+			CreatedAt:  p.CreatedAt,
+		}}
+		if err := enc.Encode(transferRecord{Type: transferPayment, Payment: rec}); err != nil {
+			return err
+		}
+		stats.Payments++
+	}
+	if err := payments.Err(); err != nil {
+		return err
+	}
+
+	subs, err := tx.query(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer subs.Close()
+	for subs.Next() {
+		var s Subscription
+		if err := subs.Scan(&s.ID, &s.UserID, &s.Source, &s.Plan, &s.StartsAt, &s.EndsAt, &s.CreatedAt); err != nil {
+			return err
+		}
+		rec := &transferSubscriptionRecord{UserID: s.UserID, UserDataSubscription: UserDataSubscription{
+			Source:   s.Source,
+			Plan:     s.Plan,
+			StartsAt: s.StartsAt,
+			EndsAt:   s.EndsAt,
+		}}
+		if err := enc.Encode(transferRecord{Type: transferSubscription, Subscription: rec}); err != nil {
+			return err
+		}
+		stats.Subscriptions++
+	}
+	if err := subs.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func transferUserFrom(u User) *transferUserRecord {
+	return &transferUserRecord{
+		UserDataProfile: UserDataProfile{
+			ID:                  u.ID,
+			Username:            u.Username,
+			FirstName:           u.FirstName,
+			LastName:            u.LastName,
+			LanguageCode:        u.LanguageCode,
+			Language:            u.Language,
+			Balance:             u.Balance,
+			IsTrial:             u.IsTrial,
+			IsActive:            u.IsActive,
+			IsFriend:            u.IsFriend,
+			SubscriptionEndDate: nullTimePtr(u.SubscriptionEndDate),
+			ReferrerID:          u.ReffererId,
+			CreatedAt:           nullTimePtr(u.CreatedAt),
+			LastSeenAt:          nullTimePtr(u.LastSeenAt),
+		},
+		BlockedBot: u.BlockedBot,
+		DeletedAt:  nullTimePtr(u.DeletedAt),
+	}
+}
+
+// transferDump — прочитанная и проверенная выгрузка.
+type transferDump struct {
+	meta          transferMetaRecord
+	users         []*transferUserRecord
+	devices       []*transferDeviceRecord
+	payments      []*transferPaymentRecord
+	subscriptions []*transferSubscriptionRecord
+}
+
+// Import загружает выгрузку Export в базу одной транзакцией. Перед записью
+// проверяется версия формата и схемы и ссылочная целостность: устройства,
+// платежи и периоды должны ссылаться на пользователей из выгрузки, а
+// пригласивший пользователь — быть в выгрузке или уже в базе. Пользователи,
+// которые уже есть в базе, не перезаписываются: импорт прерывается.
+func (db *DB) Import(ctx context.Context, r io.Reader) (TransferStats, error) {
+	var stats TransferStats
+
+	dump, err := readTransfer(r)
+	if err != nil {
+		return stats, err
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return stats, fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+	if dump.meta.SchemaVersion > version {
+		return stats, fmt.Errorf("выгрузка сделана со схемой версии %d, а база имеет версию %d: обновите бота", dump.meta.SchemaVersion, version)
+	}
+
+	err = db.WithTx(ctx, func(tx *Tx) error {
+		users := make(map[int64]bool, len(dump.users))
+		for _, u := range dump.users {
+			users[u.ID] = true
+		}
+		for _, u := range dump.users {
+			if err := tx.userExists(ctx, u.ID); err == nil {
+				return fmt.Errorf("пользователь %d уже есть в базе", u.ID)
+			} else if !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			if u.ReferrerID != 0 && !users[u.ReferrerID] {
+				if err := tx.userExists(ctx, u.ReferrerID); errors.Is(err, ErrNotFound) {
+					return fmt.Errorf("пользователь %d приглашён пользователем %d, которого нет ни в выгрузке, ни в базе", u.ID, u.ReferrerID)
+				} else if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, u := range dump.users {
+			if err := importUser(ctx, tx, u); err != nil {
+				return fmt.Errorf("ошибка загрузки пользователя %d: %w", u.ID, err)
+			}
+			stats.Users++
+			if u.ReferrerID != 0 {
+				stats.Referrals++
+			}
+		}
+		for _, d := range dump.devices {
+			device := &Device{
+				UserID:          d.UserID,
+				Slot:            d.Slot,
+				Name:            d.Name,
+				MarzbanUsername: d.MarzbanUsername,
+				Location:        d.Location,
+				Protocol:        d.Protocol,
+				Link:            d.Link,
+				SubscriptionURL: d.SubscriptionURL,
+				Status:          d.Status,
+				CreatedAt:       d.CreatedAt,
+			}
+			if err := insertDevice(ctx, tx, device); err != nil {
+				return fmt.Errorf("ошибка загрузки устройства %s: %w", d.MarzbanUsername, err)
+			}
+			stats.Devices++
+		}
+		for _, p := range dump.payments {
+			var externalID sql.NullString
+			if p.ExternalID != "" {
+				externalID = sql.NullString{String: p.ExternalID, Valid: true}
+			}
+			query := "INSERT INTO payments (user_id, amount, provider, external_id, created_at) VALUES (?, ?, ?, ?, ?)"
+			if _, err := tx.exec(ctx, query, p.UserID, p.Amount, p.Provider, externalID, p.CreatedAt.UTC()); err != nil {
+				return fmt.Errorf("ошибка загрузки платежа пользователя %d: %w", p.UserID, err)
+			}
+			stats.Payments++
+		}
+		for _, s := range dump.subscriptions {
+			sub := &Subscription{UserID: s.UserID, Source: s.Source, Plan: s.Plan, StartsAt: s.StartsAt, EndsAt: s.EndsAt}
+			if err := insertSubscription(ctx, tx, sub); err != nil {
+				return fmt.Errorf("ошибка загрузки периода подписки пользователя %d: %w", s.UserID, err)
+			}
+			stats.Subscriptions++
+		}
+		return nil
+	})
+	if err != nil {
+		return TransferStats{}, err
+	}
+	return stats, nil
+}
+
+func importUser(ctx context.Context, tx *Tx, u *transferUserRecord) error {
+	var referrer sql.NullInt64
+	if u.ReferrerID != 0 {
+		referrer = sql.NullInt64{Int64: u.ReferrerID, Valid: true}
+	}
+	query := "INSERT INTO users (id, balance, is_trial, is_active, is_friend, subscription_end_date, refferer_id, " +
+		"username, first_name, last_name, language_code, created_at, last_seen_at, blocked_bot, deleted_at, language) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := tx.exec(ctx, query, u.ID, u.Balance, u.IsTrial, u.IsActive, u.IsFriend, utcNullTime(u.SubscriptionEndDate), referrer,
+		u.Username, u.FirstName, u.LastName, u.LanguageCode, utcNullTime(u.CreatedAt), utcNullTime(u.LastSeenAt), u.BlockedBot, utcNullTime(u.DeletedAt), u.Language)
+	return err
+}
+
+func utcNullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// readTransfer читает выгрузку и проверяет её без обращения к базе.
+func readTransfer(r io.Reader) (*transferDump, error) {
+	dump := &transferDump{}
+	users := make(map[int64]bool)
+	slots := make(map[[2]int64]bool)
+	marzbanUsernames := make(map[string]bool)
+	externalIDs := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec transferRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("строка %d: %w", line, err)
+		}
+		if dump.meta.Format == 0 && rec.Type != transferMeta {
+			return nil, fmt.Errorf("строка %d: выгрузка должна начинаться с записи meta", line)
+		}
+
+		switch {
+		case rec.Type == transferMeta && rec.Meta != nil:
+			if dump.meta.Format != 0 {
+				return nil, fmt.Errorf("строка %d: повторная запись meta", line)
+			}
+			if rec.Meta.Format != transferFormat {
+				return nil, fmt.Errorf("неподдерживаемая версия формата выгрузки %d (ожидается %d)", rec.Meta.Format, transferFormat)
+			}
+			dump.meta = *rec.Meta
+		case rec.Type == transferUser && rec.User != nil:
+			if users[rec.User.ID] {
+				return nil, fmt.Errorf("строка %d: пользователь %d встречается повторно", line, rec.User.ID)
+			}
+			users[rec.User.ID] = true
+			dump.users = append(dump.users, rec.User)
+		case rec.Type == transferDevice && rec.Device != nil:
+			d := rec.Device
+			if !users[d.UserID] {
+				return nil, fmt.Errorf("строка %d: устройство %s ссылается на отсутствующего пользователя %d", line, d.MarzbanUsername, d.UserID)
+			}
+			slot := [2]int64{d.UserID, int64(d.Slot)}
+			if slots[slot] || marzbanUsernames[d.MarzbanUsername] {
+				return nil, fmt.Errorf("строка %d: устройство %s встречается повторно", line, d.MarzbanUsername)
+			}
+			slots[slot] = true
+			marzbanUsernames[d.MarzbanUsername] = true
+			dump.devices = append(dump.devices, d)
+		case rec.Type == transferPayment && rec.Payment != nil:
+			p := rec.Payment
+			if !users[p.UserID] {
+				return nil, fmt.Errorf("строка %d: платёж ссылается на отсутствующего пользователя %d", line, p.UserID)
+			}
+			if p.ExternalID != "" {
+				if externalIDs[p.ExternalID] {
+					return nil, fmt.Errorf("строка %d: платёж %s встречается повторно", line, p.ExternalID)
+				}
+				externalIDs[p.ExternalID] = true
+			}
+			dump.payments = append(dump.payments, p)
+		case rec.Type == transferSubscription && rec.Subscription != nil:
+			s := rec.Subscription
+			if !users[s.UserID] {
+				return nil, fmt.Errorf("строка %d: период подписки ссылается на отсутствующего пользователя %d", line, s.UserID)
+			}
+			if s.EndsAt.Before(s.StartsAt) {
+				return nil, fmt.Errorf("строка %d: период подписки пользователя %d заканчивается раньше, чем начинается", line, s.UserID)
+			}
+			dump.subscriptions = append(dump.subscriptions, s)
+		default:
+			return nil, fmt.Errorf("строка %d: неизвестная или пустая запись %q", line, rec.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if dump.meta.Format == 0 {
+		return nil, errors.New("выгрузка пуста")
+	}
+	return dump, nil
+}
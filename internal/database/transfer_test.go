@@ -0,0 +1,97 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, b backend) {
+		ctx := context.Background()
+		src := openTestDB(t, b)
+
+		for _, id := range []int64{1, 2} {
+			if err := src.CreateUser(ctx, id, 7); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+		if err := src.TouchUser(ctx, Profile{UserID: 1, Username: "alice", LanguageCode: "ru"}); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+		if err := src.UpdateReffererID(ctx, 1, 2); err != nil {
+			t.Fatalf("UpdateReffererID: %v", err)
+		}
+		if err := src.CreateDevice(ctx, &Device{UserID: 1, Slot: 1, MarzbanUsername: "1_device1", Link: "vless://1", Status: DeviceStatusActive}); err != nil {
+			t.Fatalf("CreateDevice: %v", err)
+		}
+		if err := src.CreatePayment(ctx, &Payment{UserID: 1, Amount: 150, Provider: "test", ExternalID: "p-1"}); err != nil {
+			t.Fatalf("CreatePayment: %v", err)
+		}
+		if _, err := src.GrantSubscription(ctx, 1, SourcePayment, "month", 30); err != nil {
+			t.Fatalf("GrantSubscription: %v", err)
+		}
+
+		var buf bytes.Buffer
+		stats, err := src.Export(ctx, &buf)
+		if err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+		want := TransferStats{Users: 2, Devices: 1, Payments: 1, Subscriptions: 3, Referrals: 1}
+		if stats != want {
+			t.Errorf("Export stats = %+v, want %+v", stats, want)
+		}
+		dump := buf.String()
+
+		dst := openTestDB(t, b)
+		stats, err = dst.Import(ctx, strings.NewReader(dump))
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if stats != want {
+			t.Errorf("Import stats = %+v, want %+v", stats, want)
+		}
+
+		for _, id := range []int64{1, 2} {
+			orig, _ := src.GetUserByID(ctx, id)
+			got, err := dst.GetUserByID(ctx, id)
+			if err != nil {
+				t.Fatalf("GetUserByID(%d): %v", id, err)
+			}
+			if got.Username != orig.Username || got.ReffererId != orig.ReffererId || got.Balance != orig.Balance ||
+				!got.SubscriptionEndDate.Time.Equal(orig.SubscriptionEndDate.Time) {
+				t.Errorf("imported user = %+v, want %+v", got, orig)
+			}
+		}
+		if d, err := dst.GetDeviceByMarzbanUsername(ctx, "1_device1"); err != nil || d.Link != "vless://1" {
+			t.Errorf("imported device = %+v, %v", d, err)
+		}
+		if subs, _ := dst.GetUserSubscriptions(ctx, 1); TotalDays(subs, SourcePayment) != 30 {
+			t.Errorf("imported subscriptions = %+v", subs)
+		}
+
+		// Повторный импорт не перезаписывает пользователей
+		if _, err := dst.Import(ctx, strings.NewReader(dump)); err == nil {
+			t.Error("second Import succeeded, want conflict")
+		}
+
+		// Ссылка на пользователя, которого нет в выгрузке
+		lines := strings.Split(strings.TrimSpace(dump), "\n")
+		var broken []string
+		for _, line := range lines {
+			if !strings.Contains(line, `"id":1,`) {
+				broken = append(broken, line)
+			}
+		}
+		if _, err := openTestDB(t, b).Import(ctx, strings.NewReader(strings.Join(broken, "\n"))); err == nil {
+			t.Error("Import without referenced user succeeded")
+		}
+
+		// Выгрузка из более новой версии схемы
+		newer := strings.Replace(dump, `"schema_version":`, `"schema_version":1000`, 1)
+		if _, err := openTestDB(t, b).Import(ctx, strings.NewReader(newer)); err == nil {
+			t.Error("Import of newer schema succeeded")
+		}
+	})
+}
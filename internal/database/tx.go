@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrDeviceLimit возвращается AddDevice, если у пользователя уже
+// максимальное для тарифа количество устройств.
+var ErrDeviceLimit = errors.New("достигнут лимит устройств")
+
+// ErrUserInactive возвращается AddDevice для пользователя без активной подписки.
+var ErrUserInactive = errors.New("подписка пользователя не активна")
+
+// Tx — транзакция с теми же помощниками запросов, что и у DB.
+type Tx struct {
+	tx      *sql.Tx
+	dialect dialect
+}
+
+// WithTx выполняет fn в транзакции. Если fn возвращает ошибку,
+// транзакция откатывается, иначе фиксируется.
+func (db *DB) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+
+	if err := fn(&Tx{tx: sqlTx, dialect: db.dialect}); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// withReadTx выполняет fn в транзакции только для чтения, все запросы
+// которой видят один снимок базы. В SQLite такая транзакция не берёт
+// блокировку записи (_txlock не применяется к ReadOnly) и не мешает боту.
+func (db *DB) withReadTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := db.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: db.dialect.snapshotIsolation, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer sqlTx.Rollback()
+
+	if err := fn(&Tx{tx: sqlTx, dialect: db.dialect}); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+func (tx *Tx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.tx.QueryRowContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+// execAffecting выполняет UPDATE/DELETE и возвращает ErrNotFound,
+// если запрос не затронул ни одной строки.
+func (tx *Tx) execAffecting(ctx context.Context, query string, args ...interface{}) error {
+	res, err := tx.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// userExists возвращает ErrNotFound, если пользователя нет.
+func (tx *Tx) userExists(ctx context.Context, userID int64) error {
+	var id int64
+	err := tx.queryRow(ctx, "SELECT id FROM users WHERE id = ?", userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeactivateUser приостанавливает доступ пользователя после окончания
+// подписки: удаляет его устройства и снимает флаги пробного периода и
+// активности одной транзакцией.
+func (db *DB) DeactivateUser(ctx context.Context, userID int64) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		res, err := tx.exec(ctx, "DELETE FROM devices WHERE user_id = ?", userID)
+		if err != nil {
+			return err
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if err := tx.execAffecting(ctx, "UPDATE users SET is_trial = FALSE, is_active = FALSE WHERE id = ?", userID); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, &Event{
+			Type:    EventUserDeactivated,
+			Actor:   ActorSystem,
+			UserID:  userID,
+			Details: map[string]interface{}{"devices_deleted": deleted},
+		})
+	})
+}
+
+// ActivateSubscription продлевает подписку до until периодом из источника
+// source (SourcePayment для оплаты, SourceAdmin для выдачи администратором):
+// пользователь становится активным и выходит из пробного периода.
+func (db *DB) ActivateSubscription(ctx context.Context, userID int64, source string, until time.Time) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		var currentEnd sql.NullTime
+		err := tx.queryRow(ctx, "SELECT subscription_end_date FROM users WHERE id = ?", userID).Scan(&currentEnd)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Период выдаётся от окончания текущей подписки; если она уже
+		// покрывает until, добавлять нечего
+		start := periodStart(time.Now().UTC(), currentEnd)
+		if until.After(start) {
+			sub := Subscription{UserID: userID, Source: source, StartsAt: start, EndsAt: until}
+			if err := insertSubscription(ctx, tx, &sub); err != nil {
+				return err
+			}
+			if err := syncSubscriptionEnd(ctx, tx, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.execAffecting(ctx, "UPDATE users SET is_active = TRUE, is_trial = FALSE WHERE id = ?", userID); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, &Event{
+			Type:    EventSubscriptionExtended,
+			Actor:   ActorSystem,
+			UserID:  userID,
+			Details: map[string]interface{}{"source": source, "until": until.UTC().Format(time.RFC3339)},
+		})
+	})
+}
+
+// AddDevice сохраняет устройство, если пользователь активен и у него меньше
+// limit устройств. Проверка и вставка выполняются в одной транзакции.
+func (db *DB) AddDevice(ctx context.Context, d *Device, limit int) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		var isActive bool
+		err := tx.queryRow(ctx, "SELECT is_active FROM users WHERE id = ?", d.UserID).Scan(&isActive)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !isActive {
+			return ErrUserInactive
+		}
+
+		var count int
+		if err := tx.queryRow(ctx, "SELECT COUNT(*) FROM devices WHERE user_id = ?", d.UserID).Scan(&count); err != nil {
+			return err
+		}
+		if count >= limit {
+			return ErrDeviceLimit
+		}
+
+		return insertDevice(ctx, tx, d)
+	})
+}
+
+// EraseUser удаляет персональные данные пользователя по его просьбе.
+// Строка пользователя остаётся с отметкой deleted_at и очищенным профилем:
+// по ней сохраняется связь с платежами и не выдаётся повторный пробный
+// период. Устройства удаляются, идентификатор платежа у провайдера
+// заменяется хешем (см. erasedExternalID), у событий стираются подробности.
+func (db *DB) EraseUser(ctx context.Context, userID int64) error {
+	return db.WithTx(ctx, func(tx *Tx) error {
+		query := "UPDATE users SET username = '', first_name = '', last_name = '', language_code = '', refferer_id = NULL," +
+			" is_active = FALSE, is_trial = FALSE, deleted_at = ? WHERE id = ? AND deleted_at IS NULL"
+		if err := tx.execAffecting(ctx, query, time.Now().UTC(), userID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM devices WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM user_states WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		if err := eraseExternalIDs(ctx, tx, userID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "UPDATE events SET details = '{}' WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+		return insertEvent(ctx, tx, &Event{Type: EventUserErased, Actor: ActorUser(userID), UserID: userID})
+	})
+}
+
+// eraseExternalIDs заменяет идентификаторы платежей пользователя хешами.
+func eraseExternalIDs(ctx context.Context, tx *Tx, userID int64) error {
+	rows, err := tx.query(ctx, "SELECT id, external_id FROM payments WHERE user_id = ? AND external_id IS NOT NULL", userID)
+	if err != nil {
+		return err
+	}
+	ids := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var externalID string
+		if err := rows.Scan(&id, &externalID); err != nil {
+			rows.Close()
+			return err
+		}
+		ids[id] = externalID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, externalID := range ids {
+		if _, err := tx.exec(ctx, "UPDATE payments SET external_id = ? WHERE id = ?", erasedExternalID(externalID), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeactivateUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if err := db.DeactivateUser(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("DeactivateUser of missing user: err = %v, want ErrNotFound", err)
+		}
+
+		for _, id := range []int64{1, 2} {
+			if err := db.CreateUser(ctx, id, 7); err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+		}
+		for _, d := range []*Device{
+			{UserID: 1, Slot: 1, MarzbanUsername: "1_device1"},
+			{UserID: 1, Slot: 2, MarzbanUsername: "1_device2"},
+			{UserID: 2, Slot: 1, MarzbanUsername: "2_device1"},
+		} {
+			if err := db.CreateDevice(ctx, d); err != nil {
+				t.Fatalf("CreateDevice: %v", err)
+			}
+		}
+
+		if err := db.DeactivateUser(ctx, 1); err != nil {
+			t.Fatalf("DeactivateUser: %v", err)
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if user.IsActive || user.IsTrial {
+			t.Errorf("user after DeactivateUser = %+v", user)
+		}
+		if devices, _ := db.GetUserDevices(ctx, 1); len(devices) != 0 {
+			t.Errorf("devices left after DeactivateUser: %+v", devices)
+		}
+		if devices, _ := db.GetUserDevices(ctx, 2); len(devices) != 1 {
+			t.Errorf("other user's devices = %+v, want 1", devices)
+		}
+	})
+}
+
+func TestActivateSubscription(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+		until := time.Date(2031, 5, 6, 7, 8, 9, 0, time.UTC)
+
+		if err := db.ActivateSubscription(ctx, 1, SourcePayment, until); !errors.Is(err, ErrNotFound) {
+			t.Errorf("ActivateSubscription of missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := db.DeactivateUser(ctx, 1); err != nil {
+			t.Fatalf("DeactivateUser: %v", err)
+		}
+		if err := db.ActivateSubscription(ctx, 1, SourcePayment, until); err != nil {
+			t.Fatalf("ActivateSubscription: %v", err)
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		if !user.IsActive || user.IsTrial || !user.SubscriptionEndDate.Time.Equal(until) {
+			t.Errorf("user after ActivateSubscription = %+v", user)
+		}
+
+		// Оплаченный период учитывается в оплаченных днях
+		subs, err := db.GetUserSubscriptions(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserSubscriptions: %v", err)
+		}
+		if n := len(subs); n == 0 || subs[n-1].Source != SourcePayment || TotalDays(subs, SourcePayment) == 0 {
+			t.Errorf("subscriptions after ActivateSubscription = %+v", subs)
+		}
+	})
+}
+
+func TestAddDevice(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if err := db.AddDevice(ctx, &Device{UserID: 1, Slot: 1, MarzbanUsername: "1_device1"}, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("AddDevice for missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		for slot := 1; slot <= 2; slot++ {
+			d := &Device{UserID: 1, Slot: slot, MarzbanUsername: fmt.Sprintf("1_device%d", slot)}
+			if err := db.AddDevice(ctx, d, 2); err != nil {
+				t.Fatalf("AddDevice slot %d: %v", slot, err)
+			}
+		}
+		if err := db.AddDevice(ctx, &Device{UserID: 1, Slot: 3, MarzbanUsername: "1_device3"}, 2); !errors.Is(err, ErrDeviceLimit) {
+			t.Errorf("AddDevice over limit: err = %v, want ErrDeviceLimit", err)
+		}
+
+		if err := db.UpdateActiveStatus(ctx, 1, false); err != nil {
+			t.Fatalf("UpdateActiveStatus: %v", err)
+		}
+		if err := db.AddDevice(ctx, &Device{UserID: 1, Slot: 3, MarzbanUsername: "1_device3"}, 5); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("AddDevice for inactive user: err = %v, want ErrUserInactive", err)
+		}
+
+		if devices, _ := db.GetUserDevices(ctx, 1); len(devices) != 2 {
+			t.Errorf("devices = %+v, want 2", devices)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// maxUserDataEvents ограничивает число событий в выгрузке данных пользователя.
+const maxUserDataEvents = 10000
+
+// UserData — всё, что бот хранит о пользователе, в виде для выгрузки в JSON.
+type UserData struct {
+	ExportedAt    time.Time              `json:"exported_at"`
+	User          UserDataProfile        `json:"user"`
+	Subscriptions []UserDataSubscription `json:"subscriptions"`
+	Devices       []UserDataDevice       `json:"devices"`
+	Payments      []UserDataPayment      `json:"payments"`
+	Events        []UserDataEvent        `json:"events"`
+}
+
+type UserDataProfile struct {
+	ID                  int64      `json:"id"`
+	Username            string     `json:"username,omitempty"`
+	FirstName           string     `json:"first_name,omitempty"`
+	LastName            string     `json:"last_name,omitempty"`
+	LanguageCode        string     `json:"language_code,omitempty"`
+	Language            string     `json:"language,omitempty"`
+	Balance             float64    `json:"balance"`
+	IsTrial             bool       `json:"is_trial"`
+	IsActive            bool       `json:"is_active"`
+	IsFriend            bool       `json:"is_friend"`
+	SubscriptionEndDate *time.Time `json:"subscription_end_date,omitempty"`
+	ReferrerID          int64      `json:"referrer_id,omitempty"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+	LastSeenAt          *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type UserDataSubscription struct {
+	Source   string    `json:"source"`
+	Plan     string    `json:"plan,omitempty"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type UserDataDevice struct {
+	Slot            int       `json:"slot"`
+	Name            string    `json:"name,omitempty"`
+	MarzbanUsername string    `json:"marzban_username"`
+	Location        string    `json:"location"`
+	Protocol        string    `json:"protocol"`
+	Link            string    `json:"link"`
+	SubscriptionURL string    `json:"subscription_url,omitempty"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type UserDataPayment struct {
+	Amount     float64   `json:"amount"`
+	Provider   string    `json:"provider,omitempty"`
+	ExternalID string    `json:"external_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UserDataEvent struct {
+	Type      string                 `json:"type"`
+	Actor     string                 `json:"actor"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// CollectUserData собирает данные пользователя из всех репозиториев.
+func CollectUserData(ctx context.Context, s Store, userID int64) (*UserData, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := s.GetUserSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := s.GetUserDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := s.GetUserPayments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.ListEvents(ctx, EventFilter{UserID: userID, Limit: maxUserDataEvents})
+	if err != nil {
+		return nil, err
+	}
+
+	data := &UserData{
+		ExportedAt: time.Now().UTC(),
+		User: UserDataProfile{
+			ID:                  user.ID,
+			Username:            user.Username,
+			FirstName:           user.FirstName,
+			LastName:            user.LastName,
+			LanguageCode:        user.LanguageCode,
+			Language:            user.Language,
+			Balance:             user.Balance,
+			IsTrial:             user.IsTrial,
+			IsActive:            user.IsActive,
+			IsFriend:            user.IsFriend,
+			SubscriptionEndDate: nullTimePtr(user.SubscriptionEndDate),
+			ReferrerID:          user.ReffererId,
+			CreatedAt:           nullTimePtr(user.CreatedAt),
+			LastSeenAt:          nullTimePtr(user.LastSeenAt),
+		},
+		Subscriptions: make([]UserDataSubscription, 0, len(subs)),
+		Devices:       make([]UserDataDevice, 0, len(devices)),
+		Payments:      make([]UserDataPayment, 0, len(payments)),
+		Events:        make([]UserDataEvent, 0, len(events)),
+	}
+	for _, sub := range subs {
+		data.Subscriptions = append(data.Subscriptions, UserDataSubscription{
+			Source:   sub.Source,
+			Plan:     sub.Plan,
+			StartsAt: sub.StartsAt,
+			EndsAt:   sub.EndsAt,
+		})
+	}
+	for _, d := range devices {
+		data.Devices = append(data.Devices, UserDataDevice{
+			Slot:            d.Slot,
+			Name:            d.Name,
+			MarzbanUsername: d.MarzbanUsername,
+			Location:        d.Location,
+			Protocol:        d.Protocol,
+			Link:            d.Link,
+			SubscriptionURL: d.SubscriptionURL,
+			Status:          d.Status,
+			CreatedAt:       d.CreatedAt,
+		})
+	}
+	for _, p := range payments {
+		data.Payments = append(data.Payments, UserDataPayment{
+			Amount:     p.Amount,
+			Provider:   p.Provider,
+			ExternalID: p.ExternalID,
+			CreatedAt:  p.CreatedAt,
+		})
+	}
+	for _, e := range events {
+		data.Events = append(data.Events, UserDataEvent{
+			Type:      e.Type,
+			Actor:     e.Actor,
+			Details:   e.Details,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return data, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCollectUserDataAndErase(t *testing.T) {
+	forEachStore(t, func(t *testing.T, db Store) {
+		ctx := context.Background()
+
+		if _, err := CollectUserData(ctx, db, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("CollectUserData of missing user: err = %v, want ErrNotFound", err)
+		}
+
+		if err := db.CreateUser(ctx, 1, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := db.TouchUser(ctx, Profile{UserID: 1, Username: "alice", FirstName: "Алиса", LanguageCode: "ru"}); err != nil {
+			t.Fatalf("TouchUser: %v", err)
+		}
+		if err := db.CreateDevice(ctx, &Device{UserID: 1, Slot: 1, MarzbanUsername: "1_device1", Link: "ss://one"}); err != nil {
+			t.Fatalf("CreateDevice: %v", err)
+		}
+		if err := db.CreatePayment(ctx, &Payment{UserID: 1, Amount: 100, Provider: "test", ExternalID: "pay-1"}); err != nil {
+			t.Fatalf("CreatePayment: %v", err)
+		}
+		if err := db.RecordEvent(ctx, &Event{Type: EventDeviceCreated, Actor: ActorUser(1), UserID: 1, Details: map[string]interface{}{"device": "1_device1"}}); err != nil {
+			t.Fatalf("RecordEvent: %v", err)
+		}
+
+		data, err := CollectUserData(ctx, db, 1)
+		if err != nil {
+			t.Fatalf("CollectUserData: %v", err)
+		}
+		if data.User.Username != "alice" || len(data.Devices) != 1 || len(data.Payments) != 1 || len(data.Events) != 2 {
+			t.Errorf("user data = %+v", data)
+		}
+
+		if err := db.EraseUser(ctx, 1); err != nil {
+			t.Fatalf("EraseUser: %v", err)
+		}
+		if err := db.EraseUser(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second EraseUser: err = %v, want ErrNotFound", err)
+		}
+
+		user, err := db.GetUserByID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetUserByID after erase: %v", err)
+		}
+		if !user.DeletedAt.Valid || user.Username != "" || user.FirstName != "" || user.LanguageCode != "" || user.IsActive {
+			t.Errorf("user after erase = %+v", user)
+		}
+
+		// Профиль удалённого пользователя не восстанавливается при следующем обращении
+		if err := db.TouchUser(ctx, Profile{UserID: 1, Username: "alice"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("TouchUser of erased user: err = %v, want ErrNotFound", err)
+		}
+
+		data, err = CollectUserData(ctx, db, 1)
+		if err != nil {
+			t.Fatalf("CollectUserData after erase: %v", err)
+		}
+		if len(data.Devices) != 0 {
+			t.Errorf("devices after erase = %+v", data.Devices)
+		}
+		if len(data.Payments) != 1 || data.Payments[0].ExternalID != erasedExternalID("pay-1") || data.Payments[0].Amount != 100 {
+			t.Errorf("payments after erase = %+v", data.Payments)
+		}
+		// Повторное уведомление о платеже не зачисляется второй раз
+		if err := db.CreateUser(ctx, 2, 7); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		if err := db.CreatePayment(ctx, &Payment{UserID: 2, Amount: 100, Provider: "test", ExternalID: "pay-1"}); !errors.Is(err, ErrDuplicatePayment) {
+			t.Errorf("CreatePayment after erase: err = %v, want ErrDuplicatePayment", err)
+		}
+		for _, e := range data.Events {
+			if len(e.Details) != 0 {
+				t.Errorf("event details kept after erase: %+v", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package i18n
+
+var en = &catalog{
+	name:   "🇬🇧 English",
+	plural: englishPlural,
+	plurals: map[string][]string{
+		"days": {"%d day", "%d days"},
+	},
+	messages: map[string]string{
+		"button.main":   "🏡 Main menu",
+		"button.back":   "◀️ Back",
+		"button.pay":    "💳 Pay",
+		"button.yes":    "✅ Yes",
+		"button.cancel": "❌ Cancel",
+
+		"error.generic":      "Something went wrong, please try again later",
+		"command.unknown":    "Unknown command. Send /start",
+		"callback.stale":     "This button is outdated, open the menu with /start",
+		"callback.no_user":   "You are not registered yet, send /start",
+		"callback.restarted": "The bot has been restarted, send /start",
+
+		"start.welcome": "Welcome to 👁NoSeeNet👁\n\n" +
+			"🔒 Secure connection\n" +
+			"🌍 Access to all websites\n" +
+			"📈 High speed",
+		"start.button": "🚀 Let's go!",
+		"start.failed": "Failed to create your account.",
+
+		"menu.trial": "Your trial period ends in: %s\n\n" +
+			"You can pay for a subscription, the paid period will be added to the days you have left.",
+		"menu.paid":     "Your subscription ends in: %s",
+		"menu.inactive": "Pay for a subscription to continue using the service.",
+		"menu.friend":   "You are using the service for free!",
+		"menu.configs":  "📶 My configs",
+		"menu.support":  "🆘 Contact support",
+		"menu.guide":    "⚙️ Setup guide",
+		"menu.settings": "🛠 Settings",
+		"menu.opened":   "Main menu",
+
+		"pay.unavailable": "Payment in the bot is not available yet, please contact support",
+
+		"started.text": "Setup guide\n\n" +
+			"Choose your operating system\n\n" +
+			"You will see detailed setup instructions with a link to download the app\n\n" +
+			"Current server:\n%s\n\n" +
+			"🟢 Tap the config to copy it:\n%s",
+		"started.answer": "Welcome aboard!",
+		"started.failed": "Failed to create a VPN config.",
+
+		"configs.title":    "📶 My configs\n\nChoose an existing config or create a new one.",
+		"configs.inactive": "Pay for a subscription to continue using the service.",
+		"configs.device":   "📱 Open device %d",
+		"configs.add":      "➕ Add config",
+
+		"device.empty": "📱 Device %d\n\nYou have no config for this device.",
+		"device.config": "📱 Device %d\n\nCurrent server:\n%s\n\n" +
+			"🟢 Tap the config to copy it:\n%s",
+		"device.disabled":       "⛔️ The config is disabled. Reissue the key to use it again.",
+		"device.qr":             "📷 QR code",
+		"device.qr_caption":     "📷 Device %d, server %s\n\nScan the code in the app on another device.",
+		"device.qr_disabled":    "The config is disabled, reissue the key",
+		"device.qr_failed":      "Failed to create a QR code, please try again later",
+		"device.revoke":         "🔄 Reissue key",
+		"device.delete":         "❌ Delete config %d",
+		"device.confirm_delete": "📱 Device %d\n\nAre you sure you want to delete this config?",
+		"device.deleted":        "📱 Device %d\n\nThe config for this device has been deleted.",
+		"device.delete_failed":  "📱 Device %d\n\nFailed to delete the config, please try again later.",
+		"device.revoke_failed":  "Failed to reissue the key, please try again later",
+		"device.enable_failed":  "The key has been reissued, but the config could not be enabled. Reissue the key again or contact support",
+		"device.limit":          "You have reached the device limit of your plan",
+		"subscription.expired":  "Your subscription has expired!",
+
+		"notify.expiring":    "Your subscription expires in %s. Please renew it to keep using the service.",
+		"notify.deactivated": "Access to the service is suspended. Pay for a subscription to keep using the service.",
+		"notify.ip_warning": "⚠️ The config of device %d is used from %d different addresses at the same time. " +
+			"One config is meant for one device — it will be disabled if this happens again.",
+		"notify.ip_disabled": "⛔️ The config of device %d has been disabled because it was used on several devices at once. " +
+			"Reissue the key or contact support.",
+
+		"guides.menu": "⚙️ Setup guide\n\n" +
+			"Choose your operating system to see step-by-step setup instructions with a link to download the app.",
+		"guides.step":        "%s — step %d of %d\n\n%s",
+		"guides.next":        "Next ▶️",
+		"guides.other":       "📋 Another platform",
+		"guides.unavailable": "The guide is temporarily unavailable",
+
+		"support.unavailable": "🆘 Support is temporarily unavailable, please try again later.",
+		"support.open_failed": "Failed to open a request, please try again later",
+		"support.opened": "🆘 Request #%d\n\n" +
+			"Describe the problem in your next message, you can attach a screenshot. " +
+			"All your messages will be forwarded to support, the answer will arrive in this chat.\n\n" +
+			"/cancel — stop the conversation",
+		"support.forwarded":      "✉️ Message sent to support (request #%d)",
+		"support.forward_failed": "Failed to send the message to support, please try again later.",
+		"support.already_closed": "The request is already closed. To write again, tap «🆘 Contact support» in the main menu.",
+		"support.closed": "✅ Request #%d is closed.\n\n" +
+			"If you still have questions, tap «🆘 Contact support» in the main menu.",
+
+		"state.expired":   "⌛ The waiting time is over, please start again. Send /start",
+		"state.none":      "Nothing to cancel. Send /start",
+		"state.cancelled": "Cancelled. Send /start to open the menu",
+		"state.answer":    "Cancelled",
+
+		"mydata.empty":   "There is no data stored about you.",
+		"mydata.failed":  "Failed to export your data, please try again later.",
+		"mydata.caption": "📄 All the data the bot stores about you",
+		"deleteme.confirm": "🗑 Data deletion\n\n" +
+			"All your configs and profile data will be deleted. The payment history will be kept " +
+			"without payment system references. Configs cannot be restored.\n\n" +
+			"Are you sure?",
+		"deleteme.accept": "✅ Yes, delete",
+		"deleteme.done":   "Your data has been deleted. Send /start to use the service again",
+		"deleteme.failed": "Failed to delete your data. Please try again later with /deleteme.",
+
+		"settings.title":   "🛠 Settings\n\nLanguage: %s",
+		"settings.changed": "Language changed",
+	},
+}
@@ -0,0 +1,129 @@
+// Package i18n содержит тексты бота на поддерживаемых языках.
+//
+// Тексты хранятся в каталогах по ключам: T возвращает сообщение,
+// N — форму слова для числа. Если перевода нет, используется язык
+// по умолчанию, а если ключа нет и там — сам ключ, чтобы пропуск
+// был заметен в интерфейсе.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Языки интерфейса.
+const (
+	Russian = "ru"
+	English = "en"
+	// Default — язык пользователей, зарегистрированных до выбора языка,
+	// и запасной язык для отсутствующих переводов
+	Default = Russian
+)
+
+// Languages — поддерживаемые языки в порядке показа в настройках.
+var Languages = []string{Russian, English}
+
+type catalog struct {
+	// name — название языка в меню настроек
+	name     string
+	messages map[string]string
+	// plurals — формы слова для числа, их порядок задаёт plural
+	plurals map[string][]string
+	plural  func(n int) int
+}
+
+var catalogs = map[string]*catalog{
+	Russian: ru,
+	English: en,
+}
+
+// Supported сообщает, что для языка есть каталог.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Match выбирает язык интерфейса по language_code из Telegram
+// ("ru", "en-US" и т.п.). Для неизвестных языков выбирается английский.
+func Match(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return Default
+	}
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if Supported(code) {
+		return code
+	}
+	return English
+}
+
+// Name возвращает название языка на нём самом.
+func Name(lang string) string {
+	return lookup(lang).name
+}
+
+// T возвращает сообщение по ключу. Если переданы args, сообщение
+// используется как формат fmt.Sprintf.
+func T(lang, key string, args ...interface{}) string {
+	msg, ok := lookup(lang).messages[key]
+	if !ok {
+		if msg, ok = catalogs[Default].messages[key]; !ok {
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// N возвращает форму по ключу для числа n, например "5 дней".
+// Формы содержат %d, куда подставляется n.
+func N(lang, key string, n int) string {
+	c := lookup(lang)
+	forms, ok := c.plurals[key]
+	if !ok {
+		c = catalogs[Default]
+		if forms, ok = c.plurals[key]; !ok {
+			return fmt.Sprintf("%d %s", n, key)
+		}
+	}
+	i := c.plural(n)
+	if i >= len(forms) {
+		i = len(forms) - 1
+	}
+	return fmt.Sprintf(forms[i], n)
+}
+
+func lookup(lang string) *catalog {
+	if c, ok := catalogs[lang]; ok {
+		return c
+	}
+	return catalogs[Default]
+}
+
+// russianPlural выбирает форму: 0 — «1 день», 1 — «2 дня», 2 — «5 дней».
+func russianPlural(n int) int {
+	if n < 0 {
+		n = -n
+	}
+	n %= 100
+	switch {
+	case n%10 == 1 && n != 11:
+		return 0
+	case n%10 >= 2 && n%10 <= 4 && (n < 12 || n > 14):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// englishPlural выбирает форму: 0 — «1 day», 1 — «2 days».
+func englishPlural(n int) int {
+	if n == 1 || n == -1 {
+		return 0
+	}
+	return 1
+}
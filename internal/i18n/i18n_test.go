@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+// TestCatalogsComplete проверяет, что в каждом каталоге есть все ключи
+// языка по умолчанию с теми же глаголами форматирования.
+func TestCatalogsComplete(t *testing.T) {
+	base := catalogs[Default]
+	for _, lang := range Languages {
+		c, ok := catalogs[lang]
+		if !ok {
+			t.Fatalf("no catalog for %s", lang)
+		}
+		for key, msg := range base.messages {
+			got, ok := c.messages[key]
+			if !ok {
+				t.Errorf("%s: missing message %q", lang, key)
+				continue
+			}
+			if want, have := verbRe.FindAllString(msg, -1), verbRe.FindAllString(got, -1); !reflect.DeepEqual(want, have) {
+				t.Errorf("%s: %q has verbs %v, want %v", lang, key, have, want)
+			}
+		}
+		for key := range c.messages {
+			if _, ok := base.messages[key]; !ok {
+				t.Errorf("%s: message %q is not in %s catalog", lang, key, Default)
+			}
+		}
+		for key := range base.plurals {
+			if _, ok := c.plurals[key]; !ok {
+				t.Errorf("%s: missing plural %q", lang, key)
+			}
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{Russian, 0, "0 дней"},
+		{Russian, 1, "1 день"},
+		{Russian, 2, "2 дня"},
+		{Russian, 4, "4 дня"},
+		{Russian, 5, "5 дней"},
+		{Russian, 11, "11 дней"},
+		{Russian, 14, "14 дней"},
+		{Russian, 21, "21 день"},
+		{Russian, 22, "22 дня"},
+		{Russian, 111, "111 дней"},
+		{English, 1, "1 day"},
+		{English, 3, "3 days"},
+		{"de", 2, "2 дня"},
+	}
+	for _, tt := range tests {
+		if got := N(tt.lang, "days", tt.n); got != tt.want {
+			t.Errorf("N(%s, days, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := map[string]string{
+		"":      Default,
+		"ru":    Russian,
+		"RU-ru": Russian,
+		"en":    English,
+		"en-US": English,
+		"de":    English,
+	}
+	for code, want := range tests {
+		if got := Match(code); got != want {
+			t.Errorf("Match(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestTranslateFallback(t *testing.T) {
+	if got := T("de", "button.main"); got != T(Default, "button.main") {
+		t.Errorf("unknown language: got %q", got)
+	}
+	if got := T(English, "no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key: got %q", got)
+	}
+	if got := T(English, "device.delete", 2); got != "❌ Delete config 2" {
+		t.Errorf("formatted message: got %q", got)
+	}
+}
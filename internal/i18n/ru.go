@@ -0,0 +1,120 @@
+package i18n
+
+// Тексты пишутся без разметки: экраны экранируют их пакетом markdown, а
+// блоки кода со ссылками подставляются вместо %s.
+var ru = &catalog{
+	name:   "🇷🇺 Русский",
+	plural: russianPlural,
+	plurals: map[string][]string{
+		"days": {"%d день", "%d дня", "%d дней"},
+	},
+	messages: map[string]string{
+		"button.main":   "🏡 В главное меню",
+		"button.back":   "◀️ Назад",
+		"button.pay":    "💳 Оплатить",
+		"button.yes":    "✅ Да",
+		"button.cancel": "❌ Отмена",
+
+		"error.generic":      "Произошла ошибка, попробуйте позже",
+		"command.unknown":    "Неизвестная команда. Введите /start",
+		"callback.stale":     "Кнопка устарела, откройте меню командой /start",
+		"callback.no_user":   "Вы ещё не зарегистрированы, введите /start",
+		"callback.restarted": "Бот был перезагружен, используйте /start",
+
+		"start.welcome": "Добро пожаловать в 👁NoSeeNet👁\n\n" +
+			"🔒 Безопасное соединение\n" +
+			"🌍 Доступ ко всем сайтам\n" +
+			"📈 Высокая скорость",
+		"start.button": "🚀 Поехали!",
+		"start.failed": "Произошла ошибка при создании пользователя.",
+
+		"menu.trial": "До окончания тестового периода осталось: %s\n\n" +
+			"Вы можете оплатить подписку, оплаченный период добавится к текущему количеству оставшихся дней.",
+		"menu.paid":     "До окончания подписки осталось: %s",
+		"menu.inactive": "Оплатите подписку, чтобы продолжить пользоваться сервисом.",
+		"menu.friend":   "Ты пользуешься сервисом бесплатно!",
+		"menu.configs":  "📶 Мои конфиги",
+		"menu.support":  "🆘 Написать в поддержку",
+		"menu.guide":    "⚙️ Инструкция использования",
+		"menu.settings": "🛠 Настройки",
+		"menu.opened":   "Выполнен переход в главное меню",
+
+		"pay.unavailable": "Оплата через бота пока недоступна, напишите в поддержку",
+
+		"started.text": "Гайд по установке\n\n" +
+			"Выберите операционную систему\n\n" +
+			"Вы увидите подробную инструкцию по настройке со ссылкой на скачивание приложения\n\n" +
+			"Текущий сервер подключения:\n%s\n\n" +
+			"🟢 Нажмите на данный конфиг и он скопируется автоматически:\n%s",
+		"started.answer": "Вы начали пользоваться сервисом!",
+		"started.failed": "Произошла ошибка при создании VPN-конфигурации.",
+
+		"configs.title":    "📶 Мои конфиги\n\nВыберите существующий конфиг, либо создайте новый.",
+		"configs.inactive": "Оплатите подписку, чтобы продолжить пользоваться сервисом.",
+		"configs.device":   "📱 Открыть устройство %d",
+		"configs.add":      "➕ Добавить конфиг",
+
+		"device.empty": "📱 Устройство %d\n\nУ вас нет конфига для этого устройства.",
+		"device.config": "📱 Устройство %d\n\nТекущий сервер подключения:\n%s\n\n" +
+			"🟢 Нажмите на данный конфиг и он скопируется автоматически:\n%s",
+		"device.disabled":       "⛔️ Конфиг отключен. Перевыпустите ключ, чтобы снова им пользоваться.",
+		"device.qr":             "📷 QR-код",
+		"device.qr_caption":     "📷 Устройство %d, сервер %s\n\nОтсканируйте код в приложении на другом устройстве.",
+		"device.qr_disabled":    "Конфиг отключен, перевыпустите ключ",
+		"device.qr_failed":      "Не удалось создать QR-код, попробуйте позже",
+		"device.revoke":         "🔄 Перевыпустить ключ",
+		"device.delete":         "❌ Удалить конфиг %d",
+		"device.confirm_delete": "📱 Устройство %d\n\nВы уверены, что хотите удалить данный конфиг?",
+		"device.deleted":        "📱 Устройство %d\n\nКонфиг для этого устройства удален.",
+		"device.delete_failed":  "📱 Устройство %d\n\nНе удалось удалить конфиг, попробуйте позже.",
+		"device.revoke_failed":  "Не удалось перевыпустить ключ, попробуйте позже",
+		"device.enable_failed":  "Ключ перевыпущен, но конфиг не удалось включить. Перевыпустите ключ ещё раз или напишите в поддержку",
+		"device.limit":          "Достигнут лимит устройств для вашего тарифа",
+		"subscription.expired":  "Срок подписки истек!",
+
+		"notify.expiring":    "Ваша подписка истекает через %s. Пожалуйста, продлите её, чтобы продолжить пользоваться услугами.",
+		"notify.deactivated": "Доступ к сервису приостановлен. Оплатите подписку, чтобы продолжить пользоваться услугами.",
+		"notify.ip_warning": "⚠️ Конфиг устройства %d используется одновременно с %d разных адресов. " +
+			"Один конфиг предназначен для одного устройства — при повторных нарушениях он будет отключен.",
+		"notify.ip_disabled": "⛔️ Конфиг устройства %d отключен из-за использования на нескольких устройствах одновременно. " +
+			"Перевыпустите ключ или напишите в поддержку.",
+
+		"guides.menu": "⚙️ Инструкция использования\n\n" +
+			"Выберите операционную систему, и вы увидите пошаговую инструкцию по настройке со ссылкой на скачивание приложения.",
+		"guides.step":        "%s — шаг %d из %d\n\n%s",
+		"guides.next":        "Далее ▶️",
+		"guides.other":       "📋 Другая платформа",
+		"guides.unavailable": "Инструкция временно недоступна",
+
+		"support.unavailable": "🆘 Поддержка временно недоступна, попробуйте позже.",
+		"support.open_failed": "Не удалось открыть обращение, попробуйте позже",
+		"support.opened": "🆘 Обращение №%d\n\n" +
+			"Опишите проблему следующим сообщением, можно приложить скриншот. " +
+			"Все ваши сообщения будут переданы в поддержку, ответ придёт в этот чат.\n\n" +
+			"/cancel — закончить переписку",
+		"support.forwarded":      "✉️ Сообщение передано в поддержку (обращение №%d)",
+		"support.forward_failed": "Не удалось передать сообщение в поддержку, попробуйте позже.",
+		"support.already_closed": "Обращение уже закрыто. Чтобы написать снова, нажмите «🆘 Написать в поддержку» в главном меню.",
+		"support.closed": "✅ Обращение №%d закрыто.\n\n" +
+			"Если вопрос остался, нажмите «🆘 Написать в поддержку» в главном меню.",
+
+		"state.expired":   "⌛ Время ожидания истекло, начните заново. Введите /start",
+		"state.none":      "Нечего отменять. Введите /start",
+		"state.cancelled": "Действие отменено. Введите /start, чтобы открыть меню",
+		"state.answer":    "Действие отменено",
+
+		"mydata.empty":   "О вас нет сохраненных данных.",
+		"mydata.failed":  "Не удалось выгрузить данные, попробуйте позже.",
+		"mydata.caption": "📄 Все данные, которые хранит о вас бот",
+		"deleteme.confirm": "🗑 Удаление данных\n\n" +
+			"Будут удалены все ваши конфиги и данные профиля. История платежей сохранится " +
+			"без привязки к платежной системе. Восстановить конфиги будет невозможно.\n\n" +
+			"Вы уверены?",
+		"deleteme.accept": "✅ Да, удалить",
+		"deleteme.done":   "Ваши данные удалены. Чтобы снова воспользоваться сервисом, введите /start",
+		"deleteme.failed": "Не удалось удалить данные. Попробуйте позже ещё раз командой /deleteme.",
+
+		"settings.title":   "🛠 Настройки\n\nЯзык: %s",
+		"settings.changed": "Язык изменён",
+	},
+}
@@ -0,0 +1,151 @@
+// Package markdown собирает тексты сообщений в разметке Telegram MarkdownV2.
+//
+// Обычный текст экранируется целиком, включая подставленные значения,
+// поэтому ссылки и названия с «.», «-», «_» и другими спецсимволами не
+// ломают отправку ошибкой «can't parse entities». Разметка добавляется
+// только через значения Code, Pre, Bold и Link.
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// specialChars — символы, которые MarkdownV2 требует экранировать вне кода.
+const specialChars = "_*[]()~`>#+-=|{}.!\\"
+
+// Границы фрагментов, которые Format уже подготовил и не экранирует повторно.
+const (
+	fragmentStart = '\x00'
+	fragmentEnd   = '\x01'
+)
+
+// Escape экранирует обычный текст.
+func Escape(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if r == fragmentStart || r == fragmentEnd {
+			continue
+		}
+		if strings.ContainsRune(specialChars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeCode экранирует текст внутри `code` и ```pre```: там специальны
+// только обратная кавычка и обратная косая черта.
+func escapeCode(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if r == fragmentStart || r == fragmentEnd {
+			continue
+		}
+		if r == '`' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// escapeURL экранирует адрес ссылки: внутри (...) специальны ) и \.
+func escapeURL(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if r == fragmentStart || r == fragmentEnd {
+			continue
+		}
+		if r == ')' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Value — готовый фрагмент разметки. Format вставляет его без экранирования.
+type Value struct {
+	markup string
+}
+
+// Code — моноширинный текст в строке. В Telegram копируется нажатием.
+func Code(s string) Value {
+	return Value{"`" + escapeCode(s) + "`"}
+}
+
+// Pre — блок кода, например ссылка конфига.
+func Pre(s string) Value {
+	return Value{"```\n" + escapeCode(s) + "\n```"}
+}
+
+func Bold(s string) Value {
+	return Value{"*" + Escape(s) + "*"}
+}
+
+func Link(text, url string) Value {
+	return Value{"[" + Escape(text) + "](" + escapeURL(url) + ")"}
+}
+
+// String возвращает разметку фрагмента.
+func (v Value) String() string {
+	return v.markup
+}
+
+// Format вызывается fmt при подстановке в шаблон Format.
+func (v Value) Format(f fmt.State, verb rune) {
+	io.WriteString(f, string(fragmentStart)+v.markup+string(fragmentEnd))
+}
+
+// plain — обычный аргумент шаблона: форматируется своим глаголом и экранируется.
+type plain struct {
+	v interface{}
+}
+
+func (p plain) Format(f fmt.State, verb rune) {
+	s := fmt.Sprintf(fmt.FormatString(f, verb), p.v)
+	io.WriteString(f, string(fragmentStart)+Escape(s)+string(fragmentEnd))
+}
+
+// Format подставляет аргументы в шаблон fmt и возвращает текст MarkdownV2.
+// Шаблон и аргументы считаются обычным текстом и экранируются, аргументы
+// типа Value вставляются как есть.
+func Format(tmpl string, args ...interface{}) string {
+	wrapped := make([]interface{}, len(args))
+	for i, arg := range args {
+		if v, ok := arg.(Value); ok {
+			wrapped[i] = v
+		} else {
+			wrapped[i] = plain{arg}
+		}
+	}
+
+	out := fmt.Sprintf(tmpl, wrapped...)
+
+	var b strings.Builder
+	b.Grow(len(out))
+	for len(out) > 0 {
+		start := strings.IndexRune(out, fragmentStart)
+		if start < 0 {
+			b.WriteString(Escape(out))
+			break
+		}
+		b.WriteString(Escape(out[:start]))
+		out = out[start+1:]
+
+		end := strings.IndexRune(out, fragmentEnd)
+		if end < 0 {
+			b.WriteString(out)
+			break
+		}
+		b.WriteString(out[:end])
+		out = out[end+1:]
+	}
+	return b.String()
+}
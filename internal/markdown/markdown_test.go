@@ -0,0 +1,91 @@
+package markdown
+
+import "testing"
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain text", "plain text"},
+		{"Done.", `Done\.`},
+		{"Sure?", "Sure?"},
+		{"_*[]()~`>#+-=|{}.!\\", `\_\*\[\]\(\)\~\` + "`" + `\>\#\+\-\=\|\{\}\.\!\\`},
+		{"🇵🇱 Польша (PL-1)", `🇵🇱 Польша \(PL\-1\)`},
+	}
+	for _, tt := range tests {
+		got := Escape(tt.in)
+		if got != tt.want {
+			t.Errorf("Escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("Escape(%q) is invalid: %v", tt.in, err)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Value
+		want string
+	}{
+		{"code", Code("a_b`c\\d"), "`a_b\\`c\\\\d`"},
+		{"pre", Pre("vless://id@host:443?type=ws#name-1."), "```\nvless://id@host:443?type=ws#name-1.\n```"},
+		{"pre backtick", Pre("a```b"), "```\na\\`\\`\\`b\n```"},
+		{"bold", Bold("Note."), `*Note\.*`},
+		{"link", Link("site.com", "https://example.com/a_(b)"), `[site\.com](https://example.com/a_(b\))`},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got.String(), tt.want)
+		}
+		if err := Validate(tt.got.String()); err != nil {
+			t.Errorf("%s: invalid markup %q: %v", tt.name, tt.got.String(), err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		tmpl string
+		args []interface{}
+		want string
+	}{
+		{"template escaped", "Device %d.\nSure?", []interface{}{2}, "Device 2\\.\nSure?"},
+		{"argument escaped", "Server: %s", []interface{}{"DE-1 (test)"}, `Server: DE\-1 \(test\)`},
+		{"value kept", "Config:\n%s", []interface{}{Pre("ss://a_b#c")}, "Config:\n```\nss://a_b#c\n```"},
+		{"width and precision", "[%5.1f]", []interface{}{2.25}, `\[  2\.2\]`},
+		{"percent", "100%% done", nil, "100% done"},
+		{"bad verb", "%d", []interface{}{"x"}, `%\!d\(string\=x\)`},
+		{"stray markers", "a\x00b\x01c", nil, "abc"},
+	}
+	for _, tt := range tests {
+		got := Format(tt.tmpl, tt.args...)
+		if got != tt.want {
+			t.Errorf("%s: Format(%q) = %q, want %q", tt.name, tt.tmpl, got, tt.want)
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("%s: invalid markup %q: %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	for _, s := range []string{
+		"Done.",
+		"a-b",
+		"*bold",
+		"```\nunclosed",
+		"```\na`b\n```",
+		"`a\\.b`",
+		"[text](url",
+		"[text]",
+		"ends with \\",
+		"\\я",
+	} {
+		if err := Validate(s); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", s)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate проверяет текст по правилам MarkdownV2, из-за которых Telegram
+// отвечает «can't parse entities»: неэкранированные спецсимволы, незакрытые
+// блоки кода, ссылки и выделения. Используется в тестах экранов.
+func Validate(s string) error {
+	runes := []rune(s)
+	// Открытые выделения: *, _, __, ~, ||
+	var open []string
+
+	toggle := func(mark string) {
+		if n := len(open); n > 0 && open[n-1] == mark {
+			open = open[:n-1]
+			return
+		}
+		open = append(open, mark)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return fmt.Errorf("позиция %d: обратная косая черта в конце текста", i)
+			}
+			if next := runes[i+1]; next < 1 || next > 126 {
+				return fmt.Errorf("позиция %d: экранировать можно только символы ASCII, получен %q", i, next)
+			}
+			i++
+		case strings.HasPrefix(string(runes[i:]), "```"):
+			end, err := closeCode(runes, i+3, "```")
+			if err != nil {
+				return err
+			}
+			i = end + 2
+		case r == '`':
+			end, err := closeCode(runes, i+1, "`")
+			if err != nil {
+				return err
+			}
+			i = end
+		case r == '[':
+			end, err := closeLink(runes, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case r == '_' && i+1 < len(runes) && runes[i+1] == '_':
+			toggle("__")
+			i++
+		case r == '|' && i+1 < len(runes) && runes[i+1] == '|':
+			toggle("||")
+			i++
+		case r == '*' || r == '_' || r == '~':
+			toggle(string(r))
+		case strings.ContainsRune(specialChars, r):
+			return fmt.Errorf("позиция %d: символ %q не экранирован", i, r)
+		}
+	}
+
+	if len(open) > 0 {
+		return fmt.Errorf("не закрыто выделение %q", open[len(open)-1])
+	}
+	return nil
+}
+
+// closeCode ищет конец блока кода, начиная с позиции from, и возвращает
+// позицию закрывающей кавычки. Внутри кода экранируются только ` и \.
+func closeCode(runes []rune, from int, fence string) (int, error) {
+	for i := from; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 >= len(runes) || (runes[i+1] != '`' && runes[i+1] != '\\') {
+				return 0, fmt.Errorf("позиция %d: внутри кода экранируются только ` и \\", i)
+			}
+			i++
+		case '`':
+			if strings.HasPrefix(string(runes[i:]), fence) {
+				return i, nil
+			}
+			return 0, fmt.Errorf("позиция %d: обратная кавычка внутри кода не экранирована", i)
+		}
+	}
+	return 0, fmt.Errorf("позиция %d: блок кода не закрыт", from-len(fence))
+}
+
+// closeLink проверяет ссылку [текст](адрес), начинающуюся с позиции from,
+// и возвращает позицию закрывающей скобки адреса.
+func closeLink(runes []rune, from int) (int, error) {
+	i := from + 1
+	for ; i < len(runes) && runes[i] != ']'; i++ {
+		if runes[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.ContainsRune(specialChars, runes[i]) {
+			return 0, fmt.Errorf("позиция %d: символ %q в тексте ссылки не экранирован", i, runes[i])
+		}
+	}
+	if i+1 >= len(runes) || runes[i+1] != '(' {
+		return 0, fmt.Errorf("позиция %d: за текстом ссылки нет адреса", from)
+	}
+
+	for i += 2; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case ')':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("позиция %d: адрес ссылки не закрыт", from)
+}
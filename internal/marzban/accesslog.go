@@ -0,0 +1,159 @@
+package marzban
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// accessLogTimeLayout — формат времени в access-логе Xray.
+const accessLogTimeLayout = "2006/01/02 15:04:05"
+
+// AccessLogEntry — одна строка access-лога Xray о принятом соединении.
+type AccessLogEntry struct {
+	Time     time.Time
+	IP       string
+	Username string
+}
+
+// ParseAccessLogLine разбирает строку access-лога Xray вида
+//
+//	2024/05/10 10:15:32 from 1.2.3.4:54321 accepted tcp:example.com:443 [Shadowsocks TCP >> DIRECT] email: 12.1_device1
+//
+// Marzban записывает в email значение "<id>.<username>", поэтому имя
+// пользователя берётся после первой точки. Строки без email или без
+// принятого соединения пропускаются.
+func ParseAccessLogLine(line string, loc *time.Location) (AccessLogEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return AccessLogEntry{}, false
+	}
+
+	ts, err := time.ParseInLocation(accessLogTimeLayout, fields[0]+" "+fields[1], loc)
+	if err != nil {
+		return AccessLogEntry{}, false
+	}
+
+	var addr string
+	for i := 2; i < len(fields); i++ {
+		if fields[i] == "accepted" && i > 2 {
+			addr = fields[i-1]
+			break
+		}
+	}
+	if addr == "" {
+		return AccessLogEntry{}, false
+	}
+
+	idx := strings.LastIndex(line, "email: ")
+	if idx < 0 {
+		return AccessLogEntry{}, false
+	}
+	email := strings.TrimSpace(line[idx+len("email: "):])
+	if dot := strings.Index(email, "."); dot >= 0 {
+		email = email[dot+1:]
+	}
+	if email == "" {
+		return AccessLogEntry{}, false
+	}
+
+	ip := parseLogAddr(addr)
+	if ip == "" {
+		return AccessLogEntry{}, false
+	}
+
+	return AccessLogEntry{Time: ts, IP: ip, Username: email}, true
+}
+
+// parseLogAddr извлекает IP из адреса вида "tcp:1.2.3.4:5678" или "[::1]:5678".
+func parseLogAddr(addr string) string {
+	addr = strings.TrimPrefix(addr, "tcp:")
+	addr = strings.TrimPrefix(addr, "udp:")
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if net.ParseIP(host) == nil {
+		return ""
+	}
+	return host
+}
+
+// maxAccessLogLine — строки длиннее пропускаются целиком: записи о
+// соединениях короткие, а без ограничения одна такая строка останавливала
+// бы чтение лога.
+const maxAccessLogLine = 64 * 1024
+
+// ReadAccessLog читает access-лог и вызывает fn для каждой записи о
+// принятом соединении. Возвращает количество байт в полных строках:
+// последняя строка без перевода строки, которую Xray ещё дописывает, не
+// считается прочитанной и разбирается при следующем чтении.
+func ReadAccessLog(r io.Reader, loc *time.Location, fn func(AccessLogEntry)) (int64, error) {
+	reader := bufio.NewReaderSize(r, maxAccessLogLine)
+
+	var consumed, skipped int64
+	for {
+		line, err := reader.ReadSlice('\n')
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			// Слишком длинная строка: пропускаем её до перевода строки
+			skipped += int64(len(line))
+			continue
+		case errors.Is(err, io.EOF):
+			return consumed, nil
+		case err != nil:
+			return consumed, err
+		}
+
+		consumed += skipped + int64(len(line))
+		if skipped > 0 {
+			skipped = 0
+			continue
+		}
+		if entry, ok := ParseAccessLogLine(string(line), loc); ok {
+			fn(entry)
+		}
+	}
+}
+
+// IPActivity — промежуток между первым и последним соединением
+// пользователя с одного IP.
+type IPActivity struct {
+	First time.Time
+	Last  time.Time
+}
+
+// Add расширяет промежуток соединением в момент t.
+func (a *IPActivity) Add(t time.Time) {
+	if a.First.IsZero() || t.Before(a.First) {
+		a.First = t
+	}
+	if t.After(a.Last) {
+		a.Last = t
+	}
+}
+
+// ConcurrentIPs возвращает наибольшее количество IP, с которых конфиг
+// использовался одновременно, то есть чьи промежутки активности
+// пересекаются. Смена сети, когда новый IP появляется после последнего
+// соединения со старого, одновременным использованием не считается.
+func ConcurrentIPs(activity map[string]IPActivity) int {
+	max := 0
+	for ip, a := range activity {
+		// Считаем IP, активные в момент появления ip
+		count := 1
+		for other, b := range activity {
+			if other != ip && !b.First.After(a.First) && b.Last.After(a.First) {
+				count++
+			}
+		}
+		if count > max {
+			max = count
+		}
+	}
+	return max
+}
@@ -0,0 +1,95 @@
+package marzban
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAccessLogLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ip   string
+		user string
+		ok   bool
+	}{
+		{"2024/05/10 10:15:32 from 1.2.3.4:54321 accepted tcp:example.com:443 [Shadowsocks TCP >> DIRECT] email: 12.1_device1", "1.2.3.4", "1_device1", true},
+		{"2024/05/10 10:15:32 tcp:5.6.7.8:1000 accepted udp:8.8.8.8:53 [Shadowsocks TCP -> DIRECT] email: 3.42_device2", "5.6.7.8", "42_device2", true},
+		{"2024/05/10 10:15:32 from [2001:db8::1]:443 accepted tcp:example.com:443 email: 7.5_device3", "2001:db8::1", "5_device3", true},
+		{"2024/05/10 10:15:32 from 1.2.3.4:54321 rejected  proxy/shadowsocks: failed to match", "", "", false},
+		{"2024/05/10 10:15:32 from 1.2.3.4:54321 accepted tcp:example.com:443 [api >> api]", "", "", false},
+		{"garbage", "", "", false},
+	}
+
+	for _, tt := range tests {
+		entry, ok := ParseAccessLogLine(tt.line, time.UTC)
+		if ok != tt.ok {
+			t.Errorf("ParseAccessLogLine(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && (entry.IP != tt.ip || entry.Username != tt.user) {
+			t.Errorf("ParseAccessLogLine(%q) = %s/%s, want %s/%s", tt.line, entry.IP, entry.Username, tt.ip, tt.user)
+		}
+	}
+}
+
+func TestReadAccessLog(t *testing.T) {
+	lines := []string{
+		"2024/05/10 10:00:00 from 1.1.1.1:1 accepted tcp:a:443 email: 1.1_device1\n",
+		"2024/05/10 10:00:01 from 2.2.2.2:1 accepted tcp:a:443 email: " + strings.Repeat("x", 2*maxAccessLogLine) + "\n",
+		"2024/05/10 10:00:02 from 3.3.3.3:1 accepted tcp:a:443 email: 2.2_device1\n",
+	}
+	// Последняя строка ещё дописывается и не должна быть прочитана
+	partial := "2024/05/10 10:00:03 from 4.4.4.4:1 accepted tcp:a:443 email: 3.3_dev"
+	log := strings.Join(lines, "") + partial
+
+	var entries []AccessLogEntry
+	consumed, err := ReadAccessLog(strings.NewReader(log), time.UTC, func(e AccessLogEntry) {
+		entries = append(entries, e)
+	})
+	if err != nil {
+		t.Fatalf("ReadAccessLog: %v", err)
+	}
+
+	if want := int64(len(log) - len(partial)); consumed != want {
+		t.Errorf("consumed %d bytes, want %d", consumed, want)
+	}
+	if len(entries) != 2 || entries[0].IP != "1.1.1.1" || entries[1].IP != "3.3.3.3" {
+		t.Errorf("entries = %+v, want 1.1.1.1 and 3.3.3.3", entries)
+	}
+}
+
+func TestConcurrentIPs(t *testing.T) {
+	at := func(min int) time.Time {
+		return time.Date(2024, 5, 10, 10, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		activity map[string]IPActivity
+		want     int
+	}{
+		{"empty", nil, 0},
+		{"single", map[string]IPActivity{"1.1.1.1": {at(0), at(10)}}, 1},
+		{"network switch", map[string]IPActivity{
+			"1.1.1.1": {at(0), at(3)},
+			"2.2.2.2": {at(4), at(10)},
+		}, 1},
+		{"two devices", map[string]IPActivity{
+			"1.1.1.1": {at(0), at(10)},
+			"2.2.2.2": {at(2), at(8)},
+		}, 2},
+		{"three devices", map[string]IPActivity{
+			"1.1.1.1": {at(0), at(10)},
+			"2.2.2.2": {at(1), at(9)},
+			"3.3.3.3": {at(5), at(5)},
+			"4.4.4.4": {at(11), at(12)},
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		if got := ConcurrentIPs(tt.activity); got != tt.want {
+			t.Errorf("%s: ConcurrentIPs = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
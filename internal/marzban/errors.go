@@ -0,0 +1,59 @@
+package marzban
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Типовые ошибки API Marzban. Проверяются через errors.Is.
+var (
+	ErrUnauthorized = errors.New("marzban: запрос не авторизован")
+	ErrUserExists   = errors.New("marzban: пользователь уже существует")
+	ErrNotFound     = errors.New("marzban: объект не найден")
+)
+
+// APIError описывает неуспешный ответ API Marzban.
+type APIError struct {
+	StatusCode int
+	Detail     string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("неудачный статус ответа: %d, тело: %s", e.StatusCode, e.Detail)
+}
+
+// Unwrap сопоставляет код ответа с типовой ошибкой, чтобы работал errors.Is.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusConflict:
+		return ErrUserExists
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return nil
+	}
+}
+
+// newAPIError формирует APIError из тела ответа. Marzban возвращает ошибки
+// в виде {"detail": "..."}; если тело другое, оно сохраняется как есть.
+func newAPIError(statusCode int, body []byte) *APIError {
+	var payload struct {
+		Detail json.RawMessage `json:"detail"`
+	}
+
+	detail := string(body)
+	if err := json.Unmarshal(body, &payload); err == nil && len(payload.Detail) > 0 {
+		var text string
+		if err := json.Unmarshal(payload.Detail, &text); err == nil {
+			detail = text
+		} else {
+			detail = string(payload.Detail)
+		}
+	}
+
+	return &APIError{StatusCode: statusCode, Detail: detail}
+}
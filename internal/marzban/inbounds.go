@@ -0,0 +1,130 @@
+package marzban
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Inbound описывает инбаунд ядра Xray, как его возвращает /api/inbounds.
+type Inbound struct {
+	Tag      string      `json:"tag"`
+	Protocol string      `json:"protocol"`
+	Network  string      `json:"network"`
+	TLS      string      `json:"tls"`
+	Port     interface{} `json:"port"` // число или строка с диапазоном портов
+}
+
+// Node описывает ноду Marzban, как её возвращает /api/nodes.
+type Node struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Address     string  `json:"address"`
+	Port        int     `json:"port"`
+	Status      string  `json:"status"`
+	Message     *string `json:"message"`
+	XrayVersion *string `json:"xray_version"`
+}
+
+// GetInbounds возвращает доступные инбаунды, сгруппированные по протоколу.
+func GetInbounds(apiURL, apiKey string) (map[string][]Inbound, error) {
+	var inbounds map[string][]Inbound
+	if err := getJSON(fmt.Sprintf("%s/api/inbounds", apiURL), apiKey, &inbounds); err != nil {
+		return nil, err
+	}
+	return inbounds, nil
+}
+
+// GetNodes возвращает список нод панели.
+func GetNodes(apiURL, apiKey string) ([]Node, error) {
+	var nodes []Node
+	if err := getJSON(fmt.Sprintf("%s/api/nodes", apiURL), apiKey, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// ValidateInbounds проверяет, что каждый настроенный протокол и тег
+// инбаунда существует в панели. Ошибка перечисляет все отсутствующие.
+func ValidateInbounds(available map[string][]Inbound, configured map[string][]string) error {
+	var missing []string
+
+	for protocol, tags := range configured {
+		known, ok := available[protocol]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("протокол %q", protocol))
+			continue
+		}
+		for _, tag := range tags {
+			found := false
+			for _, inbound := range known {
+				if inbound.Tag == tag {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, fmt.Sprintf("инбаунд %q (%s)", tag, protocol))
+			}
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf("в панели Marzban отсутствуют: %s; доступно: %s",
+		strings.Join(missing, ", "), describeInbounds(available))
+}
+
+func describeInbounds(available map[string][]Inbound) string {
+	var parts []string
+	for protocol, inbounds := range available {
+		for _, inbound := range inbounds {
+			parts = append(parts, fmt.Sprintf("%q (%s)", inbound.Tag, protocol))
+		}
+	}
+	if len(parts) == 0 {
+		return "ничего"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// getJSON выполняет авторизованный GET-запрос и декодирует ответ в out.
+func getJSON(url, apiKey string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	client := &http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения тела ответа: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("ошибка обработки ответа: %v", err)
+	}
+
+	return nil
+}
@@ -22,8 +22,9 @@ type UserRequest struct {
 
 // UserResponse представляет возможный ответ от API после создания пользователя.
 type UserResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message,omitempty"`
+	Success         bool   `json:"success"`
+	Message         string `json:"message,omitempty"`
+	SubscriptionURL string `json:"subscription_url,omitempty"`
 }
 
 func GetAPIKey(apiURL, username, password string) (string, error) {
@@ -64,7 +65,7 @@ func GetAPIKey(apiURL, username, password string) (string, error) {
 
 	// Проверяем статус ответа
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("неудачный статус ответа: %d, тело: %s", resp.StatusCode, string(respBody))
+		return "", newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Декодируем JSON-ответ
@@ -103,14 +104,27 @@ func UpdateAPIKey(configPath, newAPIKey string) error {
 	return nil
 }
 
+// DefaultInbounds — инбаунды, используемые, если в конфигурации они не заданы.
+var DefaultInbounds = map[string][]string{"shadowsocks": {"Shadowsocks TCP"}}
+
 // CreateUser отправляет запрос для создания нового пользователя на сервере Marzban.
-func CreateUser(apiURL, apiKey, username string) (*UserResponse, error) {
+// inbounds сопоставляет протокол со списком тегов инбаундов; nil означает DefaultInbounds.
+func CreateUser(apiURL, apiKey, username string, inbounds map[string][]string) (*UserResponse, error) {
 	url := fmt.Sprintf("%s/api/user", apiURL)
 
+	if len(inbounds) == 0 {
+		inbounds = DefaultInbounds
+	}
+
+	proxies := make(map[string]interface{}, len(inbounds))
+	for protocol := range inbounds {
+		proxies[protocol] = map[string]interface{}{}
+	}
+
 	reqBody := UserRequest{
 		Username:  username,
-		Proxies:   map[string]interface{}{"shadowsocks": map[string]interface{}{}},
-		Inbounds:  map[string][]string{"shadowsocks": {"Shadowsocks TCP"}},
+		Proxies:   proxies,
+		Inbounds:  inbounds,
 		Expire:    0, // Бессрочный доступ
 		DataLimit: 0, // Неограниченный трафик
 	}
@@ -147,12 +161,13 @@ func CreateUser(apiURL, apiKey, username string) (*UserResponse, error) {
 	fmt.Printf("Ответ от сервера: %s\n", string(respBody))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("неудачный статус ответа: %d, тело: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp.StatusCode, respBody)
 	}
 
 	// Временная структура для декодирования полного ответа
 	var fullResp struct {
-		Links []string `json:"links"`
+		Links           []string `json:"links"`
+		SubscriptionURL string   `json:"subscription_url"`
 	}
 
 	if err := json.Unmarshal(respBody, &fullResp); err != nil {
@@ -169,8 +184,9 @@ func CreateUser(apiURL, apiKey, username string) (*UserResponse, error) {
 
 	// Создаём и возвращаем UserResponse с первой ссылкой
 	userResp := &UserResponse{
-		Success: true,
-		Message: firstLink,
+		Success:         true,
+		Message:         firstLink,
+		SubscriptionURL: fullResp.SubscriptionURL,
 	}
 
 	return userResp, nil
@@ -197,7 +213,121 @@ func DeleteUser(apiURL, apiKey, username string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("неудачный статус ответа: %d", resp.StatusCode)
+		respBody, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+// GetUser запрашивает пользователя Marzban и возвращает его первую ссылку,
+// в том же виде, что и CreateUser.
+func GetUser(apiURL, apiKey, username string) (*UserResponse, error) {
+	url := fmt.Sprintf("%s/api/user/%s", apiURL, username)
+	return doUserRequest("GET", url, apiKey)
+}
+
+// RevokeSubscription отзывает подписку пользователя: Marzban выпускает новые
+// ключи, а старые ссылки перестают работать. Возвращает новую первую ссылку.
+func RevokeSubscription(apiURL, apiKey, username string) (*UserResponse, error) {
+	url := fmt.Sprintf("%s/api/user/%s/revoke_sub", apiURL, username)
+	return doUserRequest("POST", url, apiKey)
+}
+
+// ResetUserTraffic обнуляет счётчик использованного трафика пользователя.
+// Ссылки из ответа не нужны, поэтому пользователь без ссылок не ошибка.
+func ResetUserTraffic(apiURL, apiKey, username string) error {
+	url := fmt.Sprintf("%s/api/user/%s/reset", apiURL, username)
+	_, err := doRequest("POST", url, apiKey)
+	return err
+}
+
+// doUserRequest выполняет запрос без тела к эндпоинту, возвращающему
+// пользователя, и извлекает из ответа первую ссылку.
+func doUserRequest(method, url, apiKey string) (*UserResponse, error) {
+	respBody, err := doRequest(method, url, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var fullResp struct {
+		Links           []string `json:"links"`
+		SubscriptionURL string   `json:"subscription_url"`
+	}
+
+	if err := json.Unmarshal(respBody, &fullResp); err != nil {
+		return nil, fmt.Errorf("ошибка обработки ответа: %v", err)
+	}
+
+	if len(fullResp.Links) == 0 {
+		return nil, fmt.Errorf("в ответе отсутствуют ссылки")
+	}
+
+	return &UserResponse{
+		Success:         true,
+		Message:         fullResp.Links[0],
+		SubscriptionURL: fullResp.SubscriptionURL,
+	}, nil
+}
+
+// doRequest выполняет запрос без тела и возвращает тело успешного ответа.
+func doRequest(method, url, apiKey string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	client := &http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения тела ответа: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+	return respBody, nil
+}
+
+// SetUserStatus меняет статус пользователя Marzban ("active", "disabled").
+// Отключённый пользователь сохраняет ссылки, но не может подключиться.
+func SetUserStatus(apiURL, apiKey, username, status string) error {
+	url := fmt.Sprintf("%s/api/user/%s", apiURL, username)
+
+	body, err := json.Marshal(map[string]string{"status": status})
+	if err != nil {
+		return fmt.Errorf("ошибка формирования запроса: %v", err)
+	}
+
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	client := &http.Client{}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка выполнения запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, respBody)
 	}
 
 	return nil
@@ -0,0 +1,183 @@
+package marzban_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-vpn-bot/internal/marzban"
+	"go-vpn-bot/internal/marzban/marzbantest"
+)
+
+func TestGetAPIKey(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	token, err := marzban.GetAPIKey(srv.URL, srv.Username, srv.Password)
+	if err != nil {
+		t.Fatalf("GetAPIKey: %v", err)
+	}
+	if token != srv.Token() {
+		t.Fatalf("token = %q, want %q", token, srv.Token())
+	}
+
+	_, err = marzban.GetAPIKey(srv.URL, srv.Username, "wrong")
+	if !errors.Is(err, marzban.ErrUnauthorized) {
+		t.Fatalf("GetAPIKey with wrong password: err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	resp, err := marzban.CreateUser(srv.URL, srv.Token(), "1_device1", nil)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	u, ok := srv.User("1_device1")
+	if !ok {
+		t.Fatal("user was not created on the server")
+	}
+	if resp.Message != u.Links[0] {
+		t.Fatalf("link = %q, want %q", resp.Message, u.Links[0])
+	}
+
+	_, err = marzban.CreateUser(srv.URL, srv.Token(), "1_device1", nil)
+	if !errors.Is(err, marzban.ErrUserExists) {
+		t.Fatalf("duplicate CreateUser: err = %v, want ErrUserExists", err)
+	}
+
+	got, err := marzban.GetUser(srv.URL, srv.Token(), "1_device1")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if got.Message != resp.Message {
+		t.Fatalf("GetUser link = %q, want %q", got.Message, resp.Message)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	token := srv.Token()
+	srv.ExpireToken()
+
+	_, err := marzban.CreateUser(srv.URL, token, "1_device1", nil)
+	if !errors.Is(err, marzban.ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	srv.AddUser("1_device1")
+
+	if err := marzban.DeleteUser(srv.URL, srv.Token(), "1_device1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, ok := srv.User("1_device1"); ok {
+		t.Fatal("user still exists after delete")
+	}
+
+	err := marzban.DeleteUser(srv.URL, srv.Token(), "1_device1")
+	if !errors.Is(err, marzban.ErrNotFound) {
+		t.Fatalf("second DeleteUser: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRevokeSubscription(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	before := srv.AddUser("1_device1")
+
+	resp, err := marzban.RevokeSubscription(srv.URL, srv.Token(), "1_device1")
+	if err != nil {
+		t.Fatalf("RevokeSubscription: %v", err)
+	}
+	if resp.Message == before.Links[0] {
+		t.Fatal("link did not change after revoke")
+	}
+}
+
+func TestResetUserTraffic(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	srv.AddUser("1_device1")
+	srv.SetUsedTraffic("1_device1", 1024)
+	// Сброс не зависит от ссылок в ответе
+	srv.ClearLinks("1_device1")
+
+	if err := marzban.ResetUserTraffic(srv.URL, srv.Token(), "1_device1"); err != nil {
+		t.Fatalf("ResetUserTraffic: %v", err)
+	}
+	if u, _ := srv.User("1_device1"); u.UsedTraffic != 0 {
+		t.Fatalf("used traffic = %d, want 0", u.UsedTraffic)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	srv.FailNext(1, http.StatusInternalServerError)
+
+	_, err := marzban.CreateUser(srv.URL, srv.Token(), "1_device1", nil)
+	var apiErr *marzban.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want APIError with status 500", err)
+	}
+
+	if _, err := marzban.CreateUser(srv.URL, srv.Token(), "1_device1", nil); err != nil {
+		t.Fatalf("CreateUser after injected failure: %v", err)
+	}
+}
+
+func TestValidateInbounds(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	inbounds, err := marzban.GetInbounds(srv.URL, srv.Token())
+	if err != nil {
+		t.Fatalf("GetInbounds: %v", err)
+	}
+
+	if err := marzban.ValidateInbounds(inbounds, marzban.DefaultInbounds); err != nil {
+		t.Fatalf("ValidateInbounds(default): %v", err)
+	}
+
+	err = marzban.ValidateInbounds(inbounds, map[string][]string{
+		"shadowsocks": {"Shadowsocks UDP"},
+		"vless":       {"VLESS TCP REALITY"},
+	})
+	if err == nil {
+		t.Fatal("ValidateInbounds accepted missing inbounds")
+	}
+	for _, want := range []string{"Shadowsocks UDP", "vless"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestGetNodes(t *testing.T) {
+	srv := marzbantest.NewServer()
+	defer srv.Close()
+
+	srv.AddNode(marzbantest.Node{Name: "Poland", Address: "10.0.0.1", Port: 62050, Status: "connected"})
+
+	nodes, err := marzban.GetNodes(srv.URL, srv.Token())
+	if err != nil {
+		t.Fatalf("GetNodes: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "Poland" || nodes[0].Status != "connected" {
+		t.Fatalf("nodes = %+v", nodes)
+	}
+}
@@ -0,0 +1,505 @@
+// Package marzbantest предоставляет поддельный сервер Marzban для тестов.
+//
+// Сервер хранит пользователей в памяти, выдаёт токены администратора и
+// поддерживает внедрение сбоев (401, 5xx, задержки), чтобы сценарии бота
+// можно было проверять без живой панели.
+package marzbantest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Учётные данные администратора по умолчанию.
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "admin"
+)
+
+// Inbound — инбаунд, возвращаемый /api/inbounds.
+type Inbound struct {
+	Tag      string `json:"tag"`
+	Protocol string `json:"protocol"`
+	Network  string `json:"network"`
+	TLS      string `json:"tls"`
+	Port     int    `json:"port"`
+}
+
+// Node — нода, возвращаемая /api/nodes.
+type Node struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Address     string  `json:"address"`
+	Port        int     `json:"port"`
+	Status      string  `json:"status"`
+	Message     *string `json:"message"`
+	XrayVersion *string `json:"xray_version"`
+}
+
+// User — состояние пользователя на поддельном сервере.
+type User struct {
+	Username        string                 `json:"username"`
+	Status          string                 `json:"status"`
+	Proxies         map[string]interface{} `json:"proxies"`
+	Inbounds        map[string][]string    `json:"inbounds"`
+	Expire          int64                  `json:"expire"`
+	DataLimit       int64                  `json:"data_limit"`
+	UsedTraffic     int64                  `json:"used_traffic"`
+	LifetimeTraffic int64                  `json:"lifetime_used_traffic"`
+	CreatedAt       string                 `json:"created_at"`
+	Links           []string               `json:"links"`
+	SubscriptionURL string                 `json:"subscription_url"`
+	OnlineAt        *string                `json:"online_at"`
+
+	// revision увеличивается при каждом отзыве подписки и входит в ссылку,
+	// поэтому после revoke_sub ссылка гарантированно меняется.
+	revision int
+}
+
+// Server — поддельный Marzban поверх httptest.Server.
+type Server struct {
+	*httptest.Server
+
+	// Username и Password — учётные данные, принимаемые /api/admin/token.
+	Username string
+	Password string
+
+	mu         sync.Mutex
+	token      string
+	tokenCount int
+	users      map[string]*User
+	inbounds   map[string][]Inbound
+	nodes      []Node
+	failures   []int
+	latency    time.Duration
+	requests   int
+}
+
+// NewServer запускает поддельный сервер. Его нужно закрыть через Close.
+func NewServer() *Server {
+	s := &Server{
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		users:    make(map[string]*User),
+		inbounds: map[string][]Inbound{
+			"shadowsocks": {{Tag: "Shadowsocks TCP", Protocol: "shadowsocks", Network: "tcp", TLS: "none", Port: 1080}},
+		},
+	}
+	s.issueToken()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/admin/token", s.handleToken)
+	mux.HandleFunc("GET /api/system", s.authorized(s.handleSystem))
+	mux.HandleFunc("GET /api/inbounds", s.authorized(s.handleInbounds))
+	mux.HandleFunc("GET /api/nodes", s.authorized(s.handleNodes))
+	mux.HandleFunc("POST /api/user", s.authorized(s.handleCreateUser))
+	mux.HandleFunc("GET /api/user/{username}", s.authorized(s.handleGetUser))
+	mux.HandleFunc("PUT /api/user/{username}", s.authorized(s.handleModifyUser))
+	mux.HandleFunc("DELETE /api/user/{username}", s.authorized(s.handleDeleteUser))
+	mux.HandleFunc("GET /api/user/{username}/usage", s.authorized(s.handleUsage))
+	mux.HandleFunc("POST /api/user/{username}/revoke_sub", s.authorized(s.handleRevokeSub))
+	mux.HandleFunc("POST /api/user/{username}/reset", s.authorized(s.handleReset))
+
+	s.Server = httptest.NewServer(s.faulty(mux))
+	return s
+}
+
+// Token возвращает действующий токен администратора.
+func (s *Server) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// ExpireToken делает текущий токен недействительным: запросы с ним
+// получают 401, пока клиент не запросит новый через /api/admin/token.
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issueToken()
+}
+
+// FailNext заставляет следующие count запросов завершиться со статусом status.
+func (s *Server) FailNext(count, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// SetLatency задаёт задержку перед обработкой каждого запроса.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests возвращает количество обработанных запросов, включая неудачные.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// User возвращает копию пользователя по имени.
+func (s *Server) User(username string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// Usernames возвращает отсортированный список имён пользователей.
+func (s *Server) Usernames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.users))
+	for name := range s.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddUser создаёт пользователя напрямую, минуя API.
+func (s *Server) AddUser(username string) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.newUser(username)
+	s.users[username] = u
+	return *u
+}
+
+// SetInbounds заменяет список инбаундов. По умолчанию есть только
+// "Shadowsocks TCP".
+func (s *Server) SetInbounds(inbounds map[string][]Inbound) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inbounds = inbounds
+}
+
+// AddNode добавляет ноду и назначает ей идентификатор.
+func (s *Server) AddNode(node Node) Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node.ID = int64(len(s.nodes) + 1)
+	s.nodes = append(s.nodes, node)
+	return node
+}
+
+// SetUsedTraffic задаёт использованный трафик пользователя в байтах.
+func (s *Server) SetUsedTraffic(username string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[username]; ok {
+		u.UsedTraffic = bytes
+		if bytes > u.LifetimeTraffic {
+			u.LifetimeTraffic = bytes
+		}
+	}
+}
+
+// ClearLinks убирает ссылки пользователя, как у пользователя Marzban
+// без inbound'ов.
+func (s *Server) ClearLinks(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[username]; ok {
+		u.Links = nil
+	}
+}
+
+func (s *Server) issueToken() {
+	s.tokenCount++
+	s.token = fmt.Sprintf("test-token-%d", s.tokenCount)
+}
+
+func (s *Server) newUser(username string) *User {
+	u := &User{
+		Username:  username,
+		Status:    "active",
+		Proxies:   map[string]interface{}{"shadowsocks": map[string]interface{}{}},
+		Inbounds:  map[string][]string{"shadowsocks": {"Shadowsocks TCP"}},
+		CreatedAt: time.Now().UTC().Format("2006-01-02T15:04:05"),
+	}
+	s.refreshLinks(u)
+	return u
+}
+
+func (s *Server) refreshLinks(u *User) {
+	secret := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("chacha20-ietf-poly1305:%s-%d", u.Username, u.revision)))
+	u.Links = []string{fmt.Sprintf("ss://%s@127.0.0.1:1080#%s", secret, u.Username)}
+	u.SubscriptionURL = fmt.Sprintf("%s/sub/%s", s.urlLocked(), secret)
+}
+
+// urlLocked возвращает адрес сервера; до запуска он ещё не известен.
+func (s *Server) urlLocked() string {
+	if s.Server == nil {
+		return ""
+	}
+	return s.Server.URL
+}
+
+// faulty применяет задержку и внедрённые сбои перед передачей запроса дальше.
+func (s *Server) faulty(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		latency := s.latency
+		status := 0
+		if len(s.failures) > 0 {
+			status = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if status != 0 {
+			writeDetail(w, status, http.StatusText(status))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		valid := r.Header.Get("Authorization") == "Bearer "+s.token
+		s.mu.Unlock()
+
+		if !valid {
+			writeDetail(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeDetail(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if r.PostForm.Get("username") != s.Username || r.PostForm.Get("password") != s.Password {
+		writeDetail(w, http.StatusUnauthorized, "Incorrect username or password")
+		return
+	}
+
+	s.mu.Lock()
+	s.issueToken()
+	token := s.token
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+		"token_type":   "bearer",
+	})
+}
+
+func (s *Server) handleSystem(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var active int
+	for _, u := range s.users {
+		if u.Status == "active" {
+			active++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"version":            "0.0.0-test",
+		"mem_total":          1 << 30,
+		"mem_used":           1 << 28,
+		"cpu_cores":          1,
+		"cpu_usage":          0.0,
+		"total_user":         len(s.users),
+		"users_active":       active,
+		"incoming_bandwidth": 0,
+		"outgoing_bandwidth": 0,
+	})
+}
+
+func (s *Server) handleInbounds(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.inbounds)
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes := s.nodes
+	if nodes == nil {
+		nodes = []Node{}
+	}
+	writeJSON(w, http.StatusOK, nodes)
+}
+
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username  string                 `json:"username"`
+		Proxies   map[string]interface{} `json:"proxies"`
+		Inbounds  map[string][]string    `json:"inbounds"`
+		Expire    int64                  `json:"expire"`
+		DataLimit int64                  `json:"data_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+		writeDetail(w, http.StatusUnprocessableEntity, "Invalid user")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[req.Username]; ok {
+		writeDetail(w, http.StatusConflict, "User already exists")
+		return
+	}
+
+	u := s.newUser(req.Username)
+	if req.Proxies != nil {
+		u.Proxies = req.Proxies
+	}
+	if req.Inbounds != nil {
+		u.Inbounds = req.Inbounds
+	}
+	u.Expire = req.Expire
+	u.DataLimit = req.DataLimit
+	s.users[u.Username] = u
+
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("username")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleModifyUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status    *string             `json:"status"`
+		Inbounds  map[string][]string `json:"inbounds"`
+		Expire    *int64              `json:"expire"`
+		DataLimit *int64              `json:"data_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDetail(w, http.StatusUnprocessableEntity, "Invalid user")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("username")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if req.Status != nil {
+		u.Status = *req.Status
+	}
+	if req.Inbounds != nil {
+		u.Inbounds = req.Inbounds
+	}
+	if req.Expire != nil {
+		u.Expire = *req.Expire
+	}
+	if req.DataLimit != nil {
+		u.DataLimit = *req.DataLimit
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	username := r.PathValue("username")
+	if _, ok := s.users[username]; !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	delete(s.users, username)
+	writeDetail(w, http.StatusOK, "User successfully deleted")
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("username")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"username": u.Username,
+		"usages": []map[string]interface{}{
+			{"node_id": nil, "node_name": "Master", "used_traffic": u.UsedTraffic},
+		},
+	})
+}
+
+func (s *Server) handleRevokeSub(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("username")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	u.revision++
+	s.refreshLinks(u)
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[r.PathValue("username")]
+	if !ok {
+		writeDetail(w, http.StatusNotFound, "User not found")
+		return
+	}
+	u.UsedTraffic = 0
+	writeJSON(w, http.StatusOK, u)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeDetail(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]string{"detail": detail})
+}
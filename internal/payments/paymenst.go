@@ -2,6 +2,7 @@ package payments
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -9,11 +10,13 @@ import (
 )
 
 type PaymentNotification struct {
-	UserID int64   `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID    int64   `json:"user_id"`
+	Amount    float64 `json:"amount"`
+	Provider  string  `json:"provider"`
+	PaymentID string  `json:"payment_id"` // идентификатор платежа у провайдера, защищает от повторного зачисления
 }
 
-func HandleWebhook(db *database.DB, w http.ResponseWriter, r *http.Request) {
+func HandleWebhook(db database.PaymentRepository, w http.ResponseWriter, r *http.Request) {
 	var notification PaymentNotification
 
 	if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
@@ -21,9 +24,24 @@ func HandleWebhook(db *database.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Обновляем баланс пользователя
-	err := db.UpdateUserBalance(notification.UserID, notification.Amount)
-	if err != nil {
+	// Сохраняем платеж и обновляем баланс пользователя
+	payment := &database.Payment{
+		UserID:     notification.UserID,
+		Amount:     notification.Amount,
+		Provider:   notification.Provider,
+		ExternalID: notification.PaymentID,
+	}
+	err := db.CreatePayment(r.Context(), payment)
+	switch {
+	case errors.Is(err, database.ErrDuplicatePayment):
+		// Провайдер повторил уведомление — баланс уже пополнен
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Payment %s already processed", notification.PaymentID)
+		return
+	case errors.Is(err, database.ErrNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	case err != nil:
 		http.Error(w, "Failed to update balance", http.StatusInternalServerError)
 		return
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"go-vpn-bot/internal/bot"
 	"go-vpn-bot/internal/database"
@@ -10,16 +11,39 @@ import (
 )
 
 func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
 	// Подключение к базе данных
-	db, err := database.ConnectDB()
+	db, err := database.ConnectDB(cfg.Database.Driver, cfg.Database.DSN)
 	if err != nil {
 		log.Fatalf("Ошибка подключения к базе данных: %v", err)
 	}
 	defer db.Close()
 
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	// Служебные команды для переноса пользователей между инстансами:
+	// go-vpn-bot export --out users.jsonl / go-vpn-bot import --in users.jsonl
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "export":
+			err = runExport(db, os.Args[2:])
+		case "import":
+			err = runImport(db, os.Args[2:])
+		default:
+			log.Fatalf("Неизвестная команда %s (доступны export и import)", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Проверяем, что инбаунды из конфигурации существуют в панели
+	if err := bot.ValidateMarzbanInbounds(cfg); err != nil {
+		log.Fatalf("Ошибка проверки конфигурации Marzban: %v", err)
 	}
 
 	// Запуск Telegram-бота
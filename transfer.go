@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-vpn-bot/internal/database"
+)
+
+// runExport выполняет команду export: go-vpn-bot export --out users.jsonl
+func runExport(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	out := fs.String("out", "", "файл выгрузки (JSON Lines)")
+	fs.Parse(args)
+	if *out == "" {
+		return fmt.Errorf("не указан файл выгрузки: export --out users.jsonl")
+	}
+
+	// O_EXCL: не затираем существующую выгрузку случайно
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла выгрузки: %w", err)
+	}
+
+	stats, err := db.Export(context.Background(), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return fmt.Errorf("ошибка выгрузки: %w", err)
+	}
+
+	log.Printf("Выгрузка сохранена в %s (%s)", *out, stats)
+	return nil
+}
+
+// runImport выполняет команду import: go-vpn-bot import --in users.jsonl
+func runImport(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "", "файл выгрузки, созданный командой export")
+	fs.Parse(args)
+	if *in == "" {
+		return fmt.Errorf("не указан файл выгрузки: import --in users.jsonl")
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла выгрузки: %w", err)
+	}
+	defer f.Close()
+
+	stats, err := db.Import(context.Background(), f)
+	if err != nil {
+		return fmt.Errorf("ошибка загрузки: %w", err)
+	}
+
+	log.Printf("Загрузка из %s завершена (%s)", *in, stats)
+	return nil
+}